@@ -0,0 +1,337 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/deploy/live"
+	"github.com/kbolino/mesosdef/deploy/mock"
+	"github.com/kbolino/mesosdef/model"
+	"github.com/kbolino/mesosdef/planfile"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+var (
+	flagDeployTimeout int
+	flagLive          bool
+	flagMaxDeploy     int
+	flagReport        string
+	flagScenario      string
+	flagSeed          int64
+	flagWaitTimeout   int
+)
+
+// addFrameworkFlags adds the flags choosing and configuring the deployer for
+// the frameworks of the configuration to flags.
+func addFrameworkFlags(flags *flag.FlagSet) {
+	flags.IntVar(&flagDeployTimeout, "deployTimeout", 30, "timeout for deployment requests, in seconds")
+	flags.BoolVar(&flagLive, "live", false, "use the frameworks of the configuration instead of the mock "+
+		"deployer")
+	flags.StringVar(&flagState, "state", "mesosdef.state.json", "file recording what -live runs deployed, "+
+		"empty for none")
+	flags.IntVar(&flagWaitTimeout, "waitTimeout", 300, "timeout for waiting until deployed or healthy, "+
+		"or for hung mock phases, in seconds")
+}
+
+// addDeployerFlags adds the flags used by newDeployer and runGraphDeployer to
+// flags.
+func addDeployerFlags(flags *flag.FlagSet) {
+	addFrameworkFlags(flags)
+	flags.IntVar(&flagMaxDeploy, "maxDeploy", 5, "maximum number of simultaneous deployments")
+	flags.StringVar(&flagReport, "report", "", "write a report of deployment outcomes and timings to file")
+	flags.StringVar(&flagScenario, "scenario", "", "HCL or JSON file scripting the behavior of the mock deployer")
+	flags.Int64Var(&flagSeed, "seed", 0, "seed for the mock deployer, 0 for the scenario seed or current time")
+}
+
+// applyMain is the entry point for the apply subcommand.
+func applyMain(args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	addDeployerFlags(flags)
+	addSelectionFlags(flags)
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s apply [options] [plan file]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Deploys the deployments shown by plan, printing events as they occur.\n"+
+			"Deployments are made by a mock deployer unless -live is given, in which case\n"+
+			"deployments with no changes are only waited on until healthy.\n"+
+			"Given a plan file saved by plan -out, applies exactly that plan instead, in\n"+
+			"the same mode it was made in, refusing if it was made by another version of\n"+
+			"mesosdef, if a deployment has changed in its framework since, or, if -file is\n"+
+			"given, if the configuration or its variables have changed since.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("apply takes at most one plan file, got %d", flags.NArg())
+	} else if flags.NArg() == 1 {
+		return applyPlanFile(flags.Arg(0))
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkCycles(&cfg.graph); err != nil {
+		return err
+	}
+	selected, err := selectDeployments(cfg)
+	if err != nil {
+		return err
+	}
+	for _, warning := range selected.warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+	}
+	loader := definition.NewLoader(&cfg.root)
+	deployer, err := newDeployer(cfg, loader, flagLive)
+	if err != nil {
+		return err
+	}
+	recorder, err := newStateRecorder(&cfg.root, &cfg.graph, loader, deployer, flagLive)
+	if err != nil {
+		return err
+	}
+	graphDeployer, err := deploy.NewGraphDeployer(selected.graph, deployer, flagMaxDeploy)
+	if err != nil {
+		return fmt.Errorf("creating graph deployer: %w", err)
+	}
+	graphDeployer.SetExisting(selected.existing...)
+	graphDeployer.SetRestart(selected.restarted...)
+	// skip deployments with no changes, but still wait on them
+	if fetcher, ok := deployer.(deploy.Fetcher); ok && flagLive {
+		changes, err := planChanges(cfg, selected, compareLive(fetcher))
+		if err != nil {
+			return err
+		}
+		skipped := unchanged(changes)
+		graphDeployer.SetExisting(skipped...)
+		if recorder != nil {
+			recorder.setUnchanged(skipped...)
+		}
+		fmt.Printf("Skipping %d deployments with no changes\n", len(skipped))
+	}
+	if err := runGraphDeployer(graphDeployer, graphDeployer.Deploy, recorder); err != nil {
+		return fmt.Errorf("deploying graph: %w", err)
+	}
+	return nil
+}
+
+// applyPlanFile applies the plan saved in filename.
+func applyPlanFile(filename string) error {
+	saved, err := planfile.Read(filename)
+	if err != nil {
+		return err
+	} else if saved.MesosdefVersion != version {
+		return fmt.Errorf("plan %s was made by mesosdef %s, but this is mesosdef %s; plan again", filename,
+			saved.MesosdefVersion, version)
+	} else if len(flagTargets) != 0 || len(flagTargetLabels) != 0 || len(flagSelect) != 0 ||
+		len(flagExclude) != 0 || flagNoDeps || flagWithDependents {
+		return fmt.Errorf("deployments cannot be selected when applying a plan; plan again instead")
+	}
+	if flagFile != "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		} else if cfg.hash != saved.ConfigHash {
+			return fmt.Errorf("configuration or its variables have changed since plan %s was made; plan again",
+				filename)
+		}
+	}
+	graph, err := saved.Graph()
+	if err != nil {
+		return fmt.Errorf("plan %s is invalid: %w", filename, err)
+	}
+	cfg := &config{
+		parser: hclparse.NewParser(),
+		root:   *saved.Root(),
+	}
+	deployer, err := newDeployer(cfg, saved, saved.Live)
+	if err != nil {
+		return err
+	}
+	recorder, err := newStateRecorder(&cfg.root, graph, saved, deployer, saved.Live)
+	if err != nil {
+		return err
+	}
+	if fetcher, ok := deployer.(deploy.Fetcher); ok && saved.Live {
+		if err := checkDrift(saved, fetcher); err != nil {
+			return err
+		}
+	}
+	graphDeployer, skipped, err := newPlanGraphDeployer(saved, graph, deployer)
+	if err != nil {
+		return err
+	}
+	if recorder != nil {
+		recorder.setUnchanged(skipped...)
+	}
+	if saved.Live {
+		fmt.Printf("Skipping %d deployments with no changes\n", len(skipped))
+	}
+	if err := runGraphDeployer(graphDeployer, graphDeployer.Deploy, recorder); err != nil {
+		return fmt.Errorf("deploying graph: %w", err)
+	}
+	return nil
+}
+
+// newPlanGraphDeployer creates the graph deployer which makes the
+// deployments of saved, whose graph is graph, with deployer, along with the
+// deployments it skips because they have no changes.
+func newPlanGraphDeployer(saved *planfile.Plan, graph *model.Graph, deployer deploy.Deployer) (*deploy.GraphDeployer,
+	[]model.DeploymentRef, error) {
+	graphDeployer, err := deploy.NewGraphDeployer(graph, deployer, flagMaxDeploy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating graph deployer: %w", err)
+	}
+	var skipped []model.DeploymentRef
+	for i := range saved.Deployments {
+		deployment := &saved.Deployments[i]
+		switch deployment.Action {
+		case planfile.ActionNoOp:
+			skipped = append(skipped, deployment.Ref())
+			graphDeployer.SetExisting(deployment.Ref())
+		case planfile.ActionWait:
+			graphDeployer.SetExisting(deployment.Ref())
+		case planfile.ActionRestart:
+			graphDeployer.SetRestart(deployment.Ref())
+		}
+	}
+	return graphDeployer, skipped, nil
+}
+
+// checkDrift returns an error naming the deployments of saved which have
+// changed in their frameworks since saved was planned.
+func checkDrift(saved *planfile.Plan, fetcher deploy.Fetcher) error {
+	var drifted []string
+	for i := range saved.Deployments {
+		deployment := &saved.Deployments[i]
+		switch deployment.Action {
+		case planfile.ActionCreate, planfile.ActionUpdate, planfile.ActionNoOp:
+		default:
+			continue
+		}
+		current, err := fetcher.Fetch(deployment.Ref())
+		if err != nil {
+			return fmt.Errorf("fetching %s.%s: %w", deployment.Type, deployment.Name, err)
+		}
+		var hash string
+		if current != nil {
+			hash = planfile.Hash(current)
+		}
+		if hash != deployment.LiveHash {
+			drifted = append(drifted, fmt.Sprintf("%s.%s", deployment.Type, deployment.Name))
+		}
+	}
+	if len(drifted) != 0 {
+		return fmt.Errorf("deployment(s) %s have changed since the plan was made; plan again",
+			strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// newDeployer creates the deployer for the deployments of cfg, loading their
+// definitions from source: the live deployer if live is true, and the mock
+// deployer chosen by the deployer flags otherwise.
+func newDeployer(cfg *config, source definition.Source, live bool) (deploy.Deployer, error) {
+	if live {
+		return newLiveDeployer(&cfg.root, source)
+	}
+	var scenario mock.Scenario
+	if flagScenario != "" {
+		diags := mock.DecodeScenarioFile(cfg.parser, flagScenario, &scenario)
+		if !diags.HasErrors() {
+			diags = append(diags, scenario.Validate(&cfg.root)...)
+		}
+		if err := cfg.writeDiagnostics(diags); err != nil {
+			return nil, err
+		}
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("scenario file \"%s\" is invalid", flagScenario)
+		}
+	}
+	deployer := mock.New(&cfg.root, &scenario, flagSeed)
+	fmt.Printf("Mock deployer seed: %d\n", deployer.Seed())
+	// hung phases give up like live waits do, or when interrupted
+	deployer.SetHangTimeout(time.Duration(flagWaitTimeout) * time.Second)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		fmt.Fprintf(os.Stderr, "WARNING: interrupted, releasing hung mock phases; interrupt again to exit\n")
+		deployer.Release()
+	}()
+	return deployer, nil
+}
+
+// newLiveDeployer creates the live deployer for the deployments of root,
+// loading their definitions from source.
+func newLiveDeployer(root *model.Root, source definition.Source) (*live.Deployer, error) {
+	return live.New(root, source, time.Duration(flagDeployTimeout)*time.Second,
+		time.Duration(flagWaitTimeout)*time.Second)
+}
+
+// runGraphDeployer calls run, which is the Deploy or Destroy method of
+// graphDeployer, printing its events and results, recording them in the
+// state file if recorder is not nil, and writing a report if -report is
+// given.
+func runGraphDeployer(graphDeployer *deploy.GraphDeployer, run func(chan<- deploy.Event) error,
+	recorder *stateRecorder) error {
+	events := make(chan deploy.Event, 100)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range events {
+			printEvent(event, len(events))
+			if recorder != nil {
+				recorder.record(event)
+			}
+		}
+	}()
+	runErr := run(events)
+	wg.Wait()
+	stats := graphDeployer.Stats()
+	fmt.Printf("Result: %d successful and %d failed deployments of %d resources in %s", stats.SuccessfulDeployments,
+		stats.FailedDeployments, stats.TotalDeployments, stats.ElapsedTime.Truncate(time.Millisecond))
+	if stats.ExistingDeployments != 0 {
+		fmt.Printf(", with %d existing deployments waited on", stats.ExistingDeployments)
+	}
+	fmt.Println()
+	if flagReport != "" {
+		if err := deploy.WriteReport(flagReport, graphDeployer.Report()); err != nil {
+			return err
+		}
+	}
+	if recorder != nil {
+		fmt.Printf("State: serial %d written to %s\n", recorder.file.Serial(), flagState)
+		if runErr == nil && recorder.err != nil {
+			return recorder.err
+		}
+	}
+	return runErr
+}
+
+// printEvent prints an event, with the number of events still queued.
+func printEvent(event deploy.Event, queueLen int) {
+	var otherPart string
+	if event.Err != nil {
+		otherPart = fmt.Sprintf(", error='%s'", event.Err.Error())
+	} else if event.Dependency.Deployment != nil {
+		deployRef := event.Dependency.Deployment.Ref()
+		otherPart = fmt.Sprintf(", dependency=%s.%s", deployRef.Type, deployRef.Name)
+	}
+	deployRef := event.Deployment.Ref()
+	timeFormat := "2006-01-02T15:04:05.000Z07:00"
+	fmt.Printf("%s workerID=%d queueLen=%-3d %-25s %s.%s%s\n", time.Now().Format(timeFormat),
+		event.WorkerID, queueLen, event.Type, deployRef.Type, deployRef.Name, otherPart)
+}
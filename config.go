@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kbolino/mesosdef/model"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	flagFile  string
+	flagNoenv bool
+	flagVars  stringSliceValue
+)
+
+// addConfigFlags adds the flags used by loadConfig to flags.
+func addConfigFlags(flags *flag.FlagSet) {
+	flags.StringVar(&flagFile, "file", "", "file to parse")
+	flags.BoolVar(&flagNoenv, "noenv", false, "do not get variables from environment")
+	flags.Var(&flagVars, "var", "set a variable var=value, can be repeated")
+}
+
+// config is a loaded and validated configuration.
+type config struct {
+	parser *hclparse.Parser
+	root   model.Root
+	graph  model.Graph
+	// variables holds the values of the variables the file refers to, or
+	// of all variables given by -var if the file is JSON.
+	variables map[string]string
+	// hash is the SHA-256 hash of the file and variables, in hex.
+	hash string
+}
+
+// writeDiagnostics writes diags to stderr, with source snippets from the
+// files parsed by cfg.
+func (cfg *config) writeDiagnostics(diags hcl.Diagnostics) error {
+	if len(diags) == 0 {
+		return nil
+	}
+	color := false
+	if info, err := os.Stderr.Stat(); err == nil {
+		color = info.Mode()&os.ModeCharDevice != 0
+	}
+	diagWriter := hcl.NewDiagnosticTextWriter(os.Stderr, cfg.parser.Files(), 78, color)
+	if err := diagWriter.WriteDiagnostics(diags); err != nil {
+		return fmt.Errorf("writing diagnostics: %w", err)
+	}
+	return nil
+}
+
+// loadConfig parses and validates the file given by the config flags and
+// builds its dependency graph, writing any diagnostics to stderr.
+// The graph may still have cycles.
+func loadConfig() (*config, error) {
+	// parse variables from env & args and add to EvalContext
+	varCount := len(flagVars)
+	environ := os.Environ()
+	if !flagNoenv {
+		varCount += len(environ)
+	}
+	ctx := hcl.EvalContext{
+		Variables: make(map[string]cty.Value, varCount),
+		Functions: model.Functions(filepath.Dir(flagFile)),
+	}
+	if !flagNoenv {
+		for _, envDef := range environ {
+			parts := strings.SplitN(envDef, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			name := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			if !model.IsValidIdentifier(name) {
+				continue
+			}
+			ctx.Variables[name] = cty.StringVal(value)
+		}
+	}
+	if len(flagVars) != 0 {
+		for _, varDef := range flagVars {
+			parts := strings.SplitN(varDef, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid variable declaration \"%s\"", varDef)
+			}
+			name := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			if !model.IsValidIdentifier(name) {
+				return nil, fmt.Errorf("invalid variable name \"%s\"", name)
+			}
+			if len(value) == 0 {
+				delete(ctx.Variables, name)
+			} else {
+				ctx.Variables[name] = cty.StringVal(value)
+			}
+		}
+	}
+	// parse and validate declaration file
+	cfg := &config{
+		parser: hclparse.NewParser(),
+	}
+	diags := model.DecodeFile(cfg.parser, flagFile, &ctx, &cfg.root)
+	if !diags.HasErrors() {
+		diags = append(diags, cfg.root.Validate()...)
+	}
+	if err := cfg.writeDiagnostics(diags); err != nil {
+		return nil, err
+	}
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("file \"%s\" is invalid", flagFile)
+	}
+	cfg.variables = referencedVariables(cfg.parser.Files()[flagFile], &ctx)
+	cfg.hash = configHash(cfg.parser.Files()[flagFile].Bytes, cfg.variables)
+	// create deployment dependency graph
+	if err := cfg.graph.Build(cfg.root.Deployments...); err != nil {
+		return nil, fmt.Errorf("building dependency graph: %w", err)
+	}
+	return cfg, nil
+}
+
+// checkCycles returns an error listing the dependency cycles of graph, and
+// the dependencies which would break them, if there are any.
+func checkCycles(graph *model.Graph) error {
+	cycles := graph.Cycles()
+	if len(cycles) == 0 {
+		return nil
+	}
+	var message strings.Builder
+	message.WriteString("dependency cycle(s) detected:\n")
+	for _, cycle := range cycles {
+		fmt.Fprintf(&message, "\t=> %s\n", cycle)
+		writeEdges(&message, "\t\t", cycle)
+	}
+	message.WriteString("removing these dependencies would break all cycles:\n")
+	writeEdges(&message, "\t", graph.CycleBreakingEdges())
+	return fmt.Errorf("%s", message.String())
+}
+
+// referencedVariables returns the values of the variables of ctx which file
+// refers to.
+// Only native syntax files can be searched for references, so for JSON files
+// the values of all variables given by -var are returned instead.
+func referencedVariables(file *hcl.File, ctx *hcl.EvalContext) map[string]string {
+	variables := make(map[string]string)
+	add := func(name string) {
+		if value, ok := ctx.Variables[name]; ok && value.Type() == cty.String {
+			variables[name] = value.AsString()
+		}
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		for _, varDef := range flagVars {
+			add(strings.TrimSpace(strings.SplitN(varDef, "=", 2)[0]))
+		}
+		return variables
+	}
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		if expr, ok := node.(*hclsyntax.ScopeTraversalExpr); ok {
+			add(expr.Traversal.RootName())
+		}
+		return nil
+	})
+	return variables
+}
+
+// configHash returns the SHA-256 hash of the contents of a file and the
+// values of its variables, in hex.
+func configHash(content []byte, variables map[string]string) string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	hash.Write(content)
+	for _, name := range names {
+		fmt.Fprintf(hash, "\n%s=%q", name, variables[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package definition
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/kbolino/mesosdef/model"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Source provides the final definitions of deployments, such as a Loader
+// does from their configuration.
+type Source interface {
+	// Load returns the final definition of the deployment with the given ref.
+	Load(ref model.DeploymentRef) ([]byte, error)
+}
+
+var _ Source = &Loader{}
+
+// Loader loads the definitions of deployments, which are the JSON documents
+// describing a Marathon app or Chronos job that are submitted to a framework.
+// Exposed methods are safe to use from multiple concurrent goroutines.
+type Loader struct {
+	deploymentsByRef map[model.DeploymentRef]*model.Deployment
+	defaults         []model.Defaults
+	dir              string
+}
+
+// NewLoader creates a new Loader for the deployments of root.
+func NewLoader(root *model.Root) *Loader {
+	deploymentsByRef := make(map[model.DeploymentRef]*model.Deployment, len(root.Deployments))
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		deploymentsByRef[deployment.Ref()] = deployment
+	}
+	return &Loader{
+		deploymentsByRef: deploymentsByRef,
+		defaults:         root.Defaults,
+		dir:              root.Dir,
+	}
+}
+
+// Load returns the final definition of the deployment with the given ref.
+//
+// An inline definition is converted to JSON, otherwise the file named by the
+// deployment's deploy attribute is read, being rendered as a template first
+// if the deployment has template variables.
+// A relative deploy path is resolved against the directory of the
+// configuration file, as are paths given to file in a template.
+// Files with the extension ".yaml" or ".yml" are parsed as YAML and converted
+// to JSON, all other files are parsed as JSON.
+// The definitions of all matching defaults blocks are merged in the order
+// they are declared, then the definition is merged over them, and finally
+// the deployment's override block is merged over the result.
+// Arrays are concatenated when merging defaults and the definition and
+// replaced when merging the override block.
+// A null in a defaults or override block removes the key, while a null in
+// the definition itself is kept.
+//
+// Returns a non-nil error if the deployment does not exist, if its file
+// cannot be read or rendered, or if the result is not valid JSON or YAML.
+func (l *Loader) Load(ref model.DeploymentRef) ([]byte, error) {
+	deployment, ok := l.deploymentsByRef[ref]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not found", ref.Type, ref.Name)
+	}
+	var definition []byte
+	if !deployment.Definition.IsNull() {
+		var err error
+		definition, err = ctyjson.Marshal(deployment.Definition, deployment.Definition.Type())
+		if err != nil {
+			return nil, fmt.Errorf("converting inline definition of %s.%s: %w", ref.Type, ref.Name, err)
+		}
+	} else if deployment.TemplateVars.IsNull() {
+		var err error
+		definition, err = ioutil.ReadFile(model.ResolvePath(l.dir, deployment.Deploy))
+		if err != nil {
+			return nil, fmt.Errorf("reading definition of %s.%s: %w", ref.Type, ref.Name, err)
+		}
+	} else {
+		rendered, diags := model.RenderTemplateFile(l.dir, deployment.Deploy, deployment.TemplateVars)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("rendering definition of %s.%s: %w", ref.Type, ref.Name, diags)
+		}
+		definition = []byte(rendered)
+	}
+	var decoded interface{}
+	var err error
+	switch filepath.Ext(deployment.Deploy) {
+	case ".yaml", ".yml":
+		decoded, err = decodeYAML(deployment.Deploy, definition)
+		if err != nil {
+			return nil, fmt.Errorf("definition of %s.%s is not valid YAML: %w", ref.Type, ref.Name, err)
+		}
+	default:
+		decoded, err = decodeJSON(definition)
+		if err != nil {
+			return nil, fmt.Errorf("definition of %s.%s is not valid JSON: %s: %w", ref.Type, ref.Name,
+				jsonErrorPosition(deployment.Deploy, definition, err), err)
+		}
+	}
+	var merged interface{} = map[string]interface{}{}
+	for i := range l.defaults {
+		defaults := &l.defaults[i]
+		matches, err := defaults.Matches(deployment)
+		if err != nil {
+			return nil, fmt.Errorf("matching defaults to %s.%s: %w", ref.Type, ref.Name, err)
+		} else if !matches {
+			continue
+		}
+		defaultsDefinition, err := ctyToJSON(defaults.Definition)
+		if err != nil {
+			return nil, fmt.Errorf("converting defaults for %s.%s: %w", ref.Type, ref.Name, err)
+		}
+		merged = merge(merged, defaultsDefinition, true, true)
+	}
+	merged = merge(merged, decoded, true, false)
+	if !deployment.Override.IsNull() {
+		override, err := ctyToJSON(deployment.Override)
+		if err != nil {
+			return nil, fmt.Errorf("converting override of %s.%s: %w", ref.Type, ref.Name, err)
+		}
+		merged = merge(merged, override, false, true)
+	}
+	return encodeJSON(merged)
+}
+
+// ctyToJSON converts a cty value into a decoded JSON value.
+func ctyToJSON(value cty.Value) (interface{}, error) {
+	data, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data)
+}
@@ -0,0 +1,201 @@
+package definition
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/model"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+const testConfig = `
+defaults {
+  type = "marathon_app"
+  definition = {
+    env = { REGION = "us" }
+  }
+}
+
+deployment "marathon_app" "file" {
+  deploy = "apps/web.json"
+}
+
+deployment "marathon_app" "yaml" {
+  deploy = "apps/web.yaml"
+}
+
+deployment "marathon_app" "template" {
+  deploy        = "apps/web.tmpl.json"
+  template_vars = { instances = 3 }
+}
+
+deployment "marathon_app" "inline" {
+  definition = {
+    id  = "/inline"
+    cmd = file("apps/cmd.sh")
+  }
+}
+
+deployment "marathon_app" "override" {
+  deploy = "apps/web.json"
+  override {
+    instances = 5
+    env       = { REGION = "eu" }
+  }
+}
+
+deployment "marathon_app" "missing" {
+  deploy = "apps/missing.json"
+}
+
+deployment "marathon_app" "invalid" {
+  deploy = "apps/invalid.json"
+}
+`
+
+// testLoader writes files, keyed by slash-separated path, into a new
+// temporary directory and returns a Loader for the configuration in its file
+// config.hcl.
+func testLoader(t *testing.T, files map[string]string) *Loader {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "definition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := &hcl.EvalContext{
+		Functions: model.Functions(dir),
+	}
+	var root model.Root
+	if diags := model.DecodeFile(hclparse.NewParser(), filepath.Join(dir, "config.hcl"), ctx, &root); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	return NewLoader(&root)
+}
+
+// testLoad loads the definitions of the marathon_app deployments named by
+// the tests with loader and checks them against the expected definitions or
+// errors.
+func testLoad(t *testing.T, loader *Loader, tests []loadTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition, err := loader.Load(model.DeploymentRef{Type: "marathon_app", Name: test.name})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if actual := string(definition); actual != test.definition {
+				t.Errorf("got %s, want %s", actual, test.definition)
+			}
+		})
+	}
+}
+
+// loadTest is a test case for testLoad.
+type loadTest struct {
+	name       string
+	definition string
+	err        string
+}
+
+func TestLoaderLoad(t *testing.T) {
+	loader := testLoader(t, map[string]string{
+		"config.hcl":         testConfig,
+		"apps/web.json":      `{"id": "/web", "instances": 1}`,
+		"apps/web.yaml":      "id: /yaml\ninstances: 2\n",
+		"apps/web.tmpl.json": `{"id": "/template", "instances": ${instances}, "cmd": ${jsonencode(file("apps/cmd.sh"))}}`,
+		"apps/cmd.sh":        "run.sh",
+		"apps/invalid.json":  "{\n  \"id\": \"/invalid\",\n}",
+	})
+	testLoad(t, loader, []loadTest{
+		{name: "file", definition: `{"env":{"REGION":"us"},"id":"/web","instances":1}`},
+		{name: "yaml", definition: `{"env":{"REGION":"us"},"id":"/yaml","instances":2}`},
+		{name: "template", definition: `{"cmd":"run.sh","env":{"REGION":"us"},"id":"/template","instances":3}`},
+		{name: "inline", definition: `{"cmd":"run.sh","env":{"REGION":"us"},"id":"/inline"}`},
+		{name: "override", definition: `{"env":{"REGION":"eu"},"id":"/web","instances":5}`},
+		{name: "missing", err: "reading definition of marathon_app.missing"},
+		{name: "invalid", err: "definition of marathon_app.invalid is not valid JSON: apps/invalid.json:3:2"},
+		{name: "unknown", err: "deployment marathon_app.unknown not found"},
+	})
+}
+
+func TestLoaderLoadMerge(t *testing.T) {
+	loader := testLoader(t, map[string]string{
+		"config.hcl": `
+defaults {
+  definition = {
+    cpus = 1
+    env  = { REGION = "us", DEBUG = "1" }
+    uris = ["https://example.com/common.tgz"]
+  }
+}
+
+defaults {
+  type = "marathon_app"
+  filter {
+    key   = "labels"
+    value = "quiet"
+  }
+  definition = {
+    env = { DEBUG = null }
+  }
+}
+
+deployment "marathon_app" "append" {
+  deploy = "apps/append.json"
+}
+
+deployment "marathon_app" "null" {
+  deploy = "apps/null.json"
+  labels = ["quiet"]
+}
+
+deployment "marathon_app" "override" {
+  deploy = "apps/append.json"
+  override {
+    cpus = null
+    uris = ["https://example.com/override.tgz"]
+    env  = { REGION = "eu" }
+  }
+}
+`,
+		"apps/append.json": `{"uris": ["https://example.com/common.tgz", "https://example.com/app.tgz"], "cpus": 2}`,
+		"apps/null.json":   `{"cpus": null, "env": {"REGION": null}}`,
+	})
+	testLoad(t, loader, []loadTest{
+		{
+			name: "append",
+			definition: `{"cpus":2,"env":{"DEBUG":"1","REGION":"us"},` +
+				`"uris":["https://example.com/common.tgz","https://example.com/app.tgz"]}`,
+		},
+		{
+			name:       "null",
+			definition: `{"cpus":null,"env":{"REGION":null},"uris":["https://example.com/common.tgz"]}`,
+		},
+		{
+			name:       "override",
+			definition: `{"env":{"DEBUG":"1","REGION":"eu"},"uris":["https://example.com/override.tgz"]}`,
+		},
+	})
+}
@@ -0,0 +1,108 @@
+package definition
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// merge returns the result of deeply merging overlay over base, where both
+// are decoded JSON values.
+// Objects are merged key by key, with a null in overlay removing the key if
+// deleteNulls is true and otherwise replacing its value with null.
+// Arrays are concatenated, without repeating elements already in base, if
+// appendArrays is true and are otherwise replaced.
+// Any other value in overlay replaces the corresponding value in base.
+// Neither base nor overlay is modified.
+func merge(base, overlay interface{}, appendArrays, deleteNulls bool) interface{} {
+	switch overlayValue := overlay.(type) {
+	case map[string]interface{}:
+		baseValue, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay
+		}
+		result := make(map[string]interface{}, len(baseValue)+len(overlayValue))
+		for key, value := range baseValue {
+			result[key] = value
+		}
+		for key, value := range overlayValue {
+			if value == nil && deleteNulls {
+				delete(result, key)
+			} else if existing, exists := result[key]; exists {
+				result[key] = merge(existing, value, appendArrays, deleteNulls)
+			} else {
+				result[key] = value
+			}
+		}
+		return result
+	case []interface{}:
+		baseValue, ok := base.([]interface{})
+		if !ok || !appendArrays {
+			return overlay
+		}
+		result := make([]interface{}, len(baseValue), len(baseValue)+len(overlayValue))
+		copy(result, baseValue)
+		for _, value := range overlayValue {
+			duplicate := false
+			for _, existing := range baseValue {
+				if reflect.DeepEqual(existing, value) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				result = append(result, value)
+			}
+		}
+		return result
+	default:
+		return overlay
+	}
+}
+
+// decodeJSON decodes a JSON document, preserving the exact representation of
+// numbers.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	} else if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+// encodeJSON encodes a decoded JSON value, without escaping HTML characters.
+func encodeJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// jsonErrorPosition returns the position of a JSON syntax error in the form
+// filename:line:column, or just the filename if the position is unknown.
+func jsonErrorPosition(filename string, data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Offset > int64(len(data)) {
+		return filename
+	}
+	line, column := 1, 1
+	for _, b := range data[:syntaxErr.Offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Sprintf("%s:%d:%d", filename, line, column)
+}
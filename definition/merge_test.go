@@ -0,0 +1,104 @@
+package definition
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         string
+		overlay      string
+		appendArrays bool
+		deleteNulls  bool
+		result       string
+	}{
+		{
+			name:    "overlay wins",
+			base:    `{"cpus":1,"mem":128}`,
+			overlay: `{"cpus":2}`,
+			result:  `{"cpus":2,"mem":128}`,
+		},
+		{
+			name:    "nested objects",
+			base:    `{"env":{"A":"a","B":"b"}}`,
+			overlay: `{"env":{"B":"c","C":"c"}}`,
+			result:  `{"env":{"A":"a","B":"c","C":"c"}}`,
+		},
+		{
+			name:    "object replaces scalar",
+			base:    `{"env":"none"}`,
+			overlay: `{"env":{"A":"a"}}`,
+			result:  `{"env":{"A":"a"}}`,
+		},
+		{
+			name:         "append arrays",
+			base:         `{"uris":["a","b"]}`,
+			overlay:      `{"uris":["b","c"]}`,
+			appendArrays: true,
+			result:       `{"uris":["a","b","c"]}`,
+		},
+		{
+			name:         "append arrays of objects",
+			base:         `{"constraints":[["host","UNIQUE"]],"ports":[{"port":80}]}`,
+			overlay:      `{"constraints":[["host","UNIQUE"],["rack","GROUP_BY"]],"ports":[{"port":80},{"port":443}]}`,
+			appendArrays: true,
+			result:       `{"constraints":[["host","UNIQUE"],["rack","GROUP_BY"]],"ports":[{"port":80},{"port":443}]}`,
+		},
+		{
+			name:    "replace arrays",
+			base:    `{"uris":["a","b"]}`,
+			overlay: `{"uris":["c"]}`,
+			result:  `{"uris":["c"]}`,
+		},
+		{
+			name:         "array replaces scalar",
+			base:         `{"uris":"a"}`,
+			overlay:      `{"uris":["b"]}`,
+			appendArrays: true,
+			result:       `{"uris":["b"]}`,
+		},
+		{
+			name:        "delete null",
+			base:        `{"cpus":1,"env":{"A":"a","B":"b"}}`,
+			overlay:     `{"cpus":null,"env":{"A":null},"missing":null}`,
+			deleteNulls: true,
+			result:      `{"env":{"B":"b"}}`,
+		},
+		{
+			name:    "keep null",
+			base:    `{"cpus":1,"env":{"A":"a","B":"b"}}`,
+			overlay: `{"cpus":null,"env":{"A":null},"missing":null}`,
+			result:  `{"cpus":null,"env":{"A":null,"B":"b"},"missing":null}`,
+		},
+		{
+			name:         "scalar overlay",
+			base:         `{"cpus":1}`,
+			overlay:      `2`,
+			appendArrays: true,
+			result:       `2`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base, err := decodeJSON([]byte(test.base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			overlay, err := decodeJSON([]byte(test.overlay))
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged, err := encodeJSON(merge(base, overlay, test.appendArrays, test.deleteNulls))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := string(merged); actual != test.result {
+				t.Errorf("got %s, want %s", actual, test.result)
+			}
+			if encoded, _ := encodeJSON(base); string(encoded) != test.base {
+				t.Errorf("base was modified to %s", encoded)
+			}
+		})
+	}
+}
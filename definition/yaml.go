@@ -0,0 +1,131 @@
+package definition
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAML decodes a YAML document into a decoded JSON value, so that YAML
+// definitions can be handled exactly like JSON definitions.
+// Errors include the given filename and the line of the offending node.
+func decodeYAML(filename string, data []byte) (interface{}, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, fmt.Errorf("%s: document is empty", filename)
+	}
+	return yamlNodeToJSON(filename, document.Content[0])
+}
+
+// yamlNodeToJSON converts a YAML node into a decoded JSON value.
+func yamlNodeToJSON(filename string, node *yaml.Node) (interface{}, error) {
+	nodeError := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s:%d:%d: %s", filename, node.Line, node.Column, fmt.Sprintf(format, args...))
+	}
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlNodeToJSON(filename, node.Alias)
+	case yaml.MappingNode:
+		result := make(map[string]interface{}, len(node.Content)/2)
+		// merge keys are applied first, so that explicit keys override them
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge" {
+				if err := mergeYAML(filename, result, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s:%d:%d: mapping key must be a scalar", filename, key.Line, key.Column)
+			} else if key.ShortTag() == "!!merge" {
+				continue
+			}
+			converted, err := yamlNodeToJSON(filename, value)
+			if err != nil {
+				return nil, err
+			}
+			result[key.Value] = converted
+		}
+		return result, nil
+	case yaml.SequenceNode:
+		result := make([]interface{}, len(node.Content))
+		for i, element := range node.Content {
+			converted, err := yamlNodeToJSON(filename, element)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!str", "!!timestamp", "!!binary":
+			return node.Value, nil
+		case "!!bool":
+			var value bool
+			if err := node.Decode(&value); err != nil {
+				return nil, nodeError("%s", err)
+			}
+			return value, nil
+		case "!!int":
+			var value int64
+			if err := node.Decode(&value); err != nil {
+				return nil, nodeError("%s", err)
+			}
+			return json.Number(strconv.FormatInt(value, 10)), nil
+		case "!!float":
+			var value float64
+			if err := node.Decode(&value); err != nil {
+				return nil, nodeError("%s", err)
+			} else if math.IsInf(value, 0) || math.IsNaN(value) {
+				return nil, nodeError("%s cannot be represented in JSON", node.Value)
+			}
+			return json.Number(strconv.FormatFloat(value, 'g', -1, 64)), nil
+		default:
+			return nil, nodeError("unsupported tag %s", node.Tag)
+		}
+	default:
+		return nil, nodeError("unsupported node")
+	}
+}
+
+// mergeYAML merges the value of a merge key (<<) into result, which must be a
+// mapping or a sequence of mappings, with earlier mappings in a sequence
+// taking precedence over later ones.
+func mergeYAML(filename string, result map[string]interface{}, node *yaml.Node) error {
+	var mappings []*yaml.Node
+	if node.Kind == yaml.SequenceNode {
+		mappings = node.Content
+	} else {
+		mappings = []*yaml.Node{node}
+	}
+	for i := len(mappings) - 1; i >= 0; i-- {
+		mapping := mappings[i]
+		for mapping.Kind == yaml.AliasNode {
+			mapping = mapping.Alias
+		}
+		if mapping.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s:%d:%d: merge key value must be a mapping or a sequence of mappings",
+				filename, mappings[i].Line, mappings[i].Column)
+		}
+		converted, err := yamlNodeToJSON(filename, mapping)
+		if err != nil {
+			return err
+		}
+		for key, value := range converted.(map[string]interface{}) {
+			result[key] = value
+		}
+	}
+	return nil
+}
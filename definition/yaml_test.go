@@ -0,0 +1,71 @@
+package definition
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+		err  string
+	}{
+		{
+			name: "scalars",
+			yaml: "id: /web\ninstances: 2\ncpus: 0.5\nenabled: true\nargs: ~\n",
+			json: `{"args":null,"cpus":0.5,"enabled":true,"id":"/web","instances":2}`,
+		},
+		{
+			name: "alias",
+			yaml: "env: &env {A: a}\napp: {env: *env}\n",
+			json: `{"app":{"env":{"A":"a"}},"env":{"A":"a"}}`,
+		},
+		{
+			name: "merge key",
+			yaml: "base: &base {cpus: 1, mem: 128}\napp:\n  <<: *base\n  cpus: 2\n",
+			json: `{"app":{"cpus":2,"mem":128},"base":{"cpus":1,"mem":128}}`,
+		},
+		{
+			name: "merge key sequence",
+			yaml: "a: &a {cpus: 1, mem: 128}\nb: &b {mem: 256, disk: 10}\napp:\n  <<: [*a, *b]\n",
+			json: `{"a":{"cpus":1,"mem":128},"app":{"cpus":1,"disk":10,"mem":128},"b":{"disk":10,"mem":256}}`,
+		},
+		{
+			name: "merge key scalar",
+			yaml: "app:\n  <<: 3\n",
+			err:  "app.yaml:2:7: merge key value must be a mapping or a sequence of mappings",
+		},
+		{
+			name: "infinity",
+			yaml: "cpus: .inf\n",
+			err:  "app.yaml:1:7: .inf cannot be represented in JSON",
+		},
+		{
+			name: "empty",
+			yaml: "",
+			err:  "app.yaml: document is empty",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := decodeYAML("app.yaml", []byte(test.yaml))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := json.Marshal(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != test.json {
+				t.Errorf("got %s, want %s", actual, test.json)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package chronos provides a Deployer which deploys jobs to Chronos through
+// its REST API.
+package chronos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/deploy/internal/normalize"
+	"github.com/kbolino/mesosdef/deploy/internal/rest"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Deployer is a deploy.Deployer which deploys the definitions of chronos_job
+// deployments as Chronos jobs.
+// Chronos jobs have no health checks, so they are healthy once deployed.
+type Deployer struct {
+	client *rest.Client
+	source definition.Source
+}
+
+var (
+	_ deploy.Deployer   = &Deployer{}
+	_ deploy.Destroyer  = &Deployer{}
+	_ deploy.Fetcher    = &Deployer{}
+	_ deploy.Identifier = &Deployer{}
+)
+
+// rules normalizes job definitions, with the defaults given by the Chronos 3.0
+// API.
+var rules = normalize.Rules{
+	Defaults: map[string]interface{}{
+		"async":                    false,
+		"concurrent":               false,
+		"container.forcePullImage": false,
+		"container.network":        "HOST",
+		"container.volumes[].mode": "RW",
+		"cpus":                     0.1,
+		"dataProcessingJobType":    false,
+		"description":              "",
+		"disabled":                 false,
+		"disk":                     256.0,
+		"epsilon":                  "PT60S",
+		"executor":                 "",
+		"executorFlags":            "",
+		"fetch[].cache":            false,
+		"fetch[].executable":       false,
+		"fetch[].extract":          true,
+		"highPriority":             false,
+		"maxCompletionTime":        0.0,
+		"mem":                      128.0,
+		"owner":                    "",
+		"ownerName":                "",
+		"retries":                  2.0,
+		"runAsUser":                "root",
+		"scheduleTimeZone":         "",
+		"shell":                    true,
+		"softError":                false,
+		"taskInfoData":             "",
+	},
+	ServerFields: []string{"errorCount", "errorsSinceLastSuccess", "lastError", "lastSuccess", "successCount"},
+	Unordered:    []string{"constraints", "environmentVariables", "fetch", "parents", "uris"},
+}
+
+// New creates a Deployer for the Chronos framework with the given masters,
+// loading definitions from source.
+// Each request gives up after requestTimeout.
+func New(masters []string, source definition.Source, requestTimeout time.Duration) *Deployer {
+	return &Deployer{
+		client: rest.NewClient(masters, requestTimeout),
+		source: source,
+	}
+}
+
+// job holds the fields of a Chronos job definition used by Deployer.
+type job struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"`
+	Parents  []string `json:"parents"`
+}
+
+// Deploy creates or replaces the job of ref, as a scheduled job if its
+// definition has a schedule and as a dependent job otherwise.
+func (d *Deployer) Deploy(ref model.DeploymentRef) error {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return err
+	}
+	var j job
+	if err := json.Unmarshal(content, &j); err != nil {
+		return fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	path := "/v1/scheduler/iso8601"
+	if j.Schedule == "" {
+		if len(j.Parents) == 0 {
+			return fmt.Errorf("definition of %s.%s: job has neither schedule nor parents", ref.Type, ref.Name)
+		}
+		path = "/v1/scheduler/dependency"
+	}
+	return d.client.Do("POST", path, content, nil)
+}
+
+// Destroy deletes the job of ref.
+// A job which does not exist is considered already destroyed.
+func (d *Deployer) Destroy(ref model.DeploymentRef) error {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return err
+	}
+	name, err := JobName(content)
+	if err != nil {
+		return fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	err = d.client.Do("DELETE", "/v1/scheduler/job/"+url.PathEscape(name), nil, nil)
+	if rest.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// WaitUntilHealthy returns immediately, since Chronos jobs have no health
+// checks.
+func (d *Deployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	return nil
+}
+
+// Fetch returns the normalized definition of the job of ref as Chronos
+// reports it, or nil if there is no such job.
+func (d *Deployer) Fetch(ref model.DeploymentRef) ([]byte, error) {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	name, err := JobName(content)
+	if err != nil {
+		return nil, fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	var jobs []json.RawMessage
+	if err := d.client.Do("GET", "/v1/scheduler/jobs/search?name="+url.QueryEscape(name), nil, &jobs); err != nil {
+		return nil, err
+	}
+	// search matches by substring, so look for the exact name
+	for _, content := range jobs {
+		if found, err := JobName(content); err == nil && found == name {
+			return rules.Apply(content)
+		}
+	}
+	return nil, nil
+}
+
+// Identify returns the job name of ref, since Chronos jobs have no versions.
+func (d *Deployer) Identify(ref model.DeploymentRef) (string, string, error) {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return "", "", err
+	}
+	name, err := JobName(content)
+	if err != nil {
+		return "", "", fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	return name, "", nil
+}
+
+// Normalize returns the normalized form of definition, the definition of ref.
+func (d *Deployer) Normalize(ref model.DeploymentRef, definition []byte) ([]byte, error) {
+	return rules.Apply(definition)
+}
+
+// JobName returns the name field of a Chronos job definition.
+func JobName(content []byte) (string, error) {
+	var j job
+	if err := json.Unmarshal(content, &j); err != nil {
+		return "", err
+	} else if j.Name == "" {
+		return "", fmt.Errorf("job has no name")
+	}
+	return j.Name, nil
+}
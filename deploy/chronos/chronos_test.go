@@ -0,0 +1,100 @@
+package chronos
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/diff"
+)
+
+// testJob returns the definition in testdata/job.json with edit applied, and
+// the job as Chronos reports it for that definition in
+// testdata/jobs-search-response.json.
+func testJob(t *testing.T, edit func(job map[string]interface{})) ([]byte, []byte) {
+	t.Helper()
+	content, err := ioutil.ReadFile("testdata/job.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j map[string]interface{}
+	if err := json.Unmarshal(content, &j); err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(j)
+	}
+	definition, err := json.Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = ioutil.ReadFile("testdata/jobs-search-response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs []json.RawMessage
+	if err := json.Unmarshal(content, &jobs); err != nil {
+		t.Fatal(err)
+	}
+	return definition, jobs[0]
+}
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(job map[string]interface{})
+		want string
+	}{
+		{
+			name: "unchanged",
+		},
+		{
+			name: "defaults written out",
+			edit: func(job map[string]interface{}) {
+				job["description"] = ""
+				job["runAsUser"] = "root"
+				job["retries"] = 2
+				job["fetch"] = []interface{}{}
+			},
+		},
+		{
+			name: "changed fields",
+			edit: func(job map[string]interface{}) {
+				job["owner"] = "reports@example.com"
+				job["runAsUser"] = "reports"
+				job["environmentVariables"] = []interface{}{
+					map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+					map[string]interface{}{"name": "REPORT_DIR", "value": "/data/reports"},
+				}
+			},
+			want: "~ environmentVariables[0].value: \"info\" => \"debug\"\n" +
+				"~ owner: \"\" => \"reports@example.com\"\n" +
+				"~ runAsUser: \"root\" => \"reports\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			definition, live := testJob(t, c.edit)
+			desired, err := rules.Apply(definition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, err := rules.Apply(live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := diff.Compare(current, desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := make([]string, len(changes))
+			for i, change := range changes {
+				lines[i] = change.String()
+			}
+			if got := strings.Join(lines, "\n"); got != c.want {
+				t.Errorf("got changes\n%s\nwant\n%s", got, c.want)
+			}
+		})
+	}
+}
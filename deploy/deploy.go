@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/kbolino/mesosdef/model"
 )
@@ -21,6 +22,44 @@ type Deployer interface {
 	WaitUntilHealthy(ref model.DeploymentRef) error
 }
 
+// Restarter is implemented by a Deployer which can restart the deployed
+// resources of a deployment without redeploying them.
+type Restarter interface {
+	// Restart restarts the deployed resources of ref, blocking until the
+	// framework reports it is complete.
+	Restart(ref model.DeploymentRef) error
+}
+
+// Destroyer is implemented by a Deployer which can remove the deployed
+// resources of a deployment from its framework.
+type Destroyer interface {
+	// Destroy removes the deployed resources of ref, blocking until the
+	// framework reports it is complete.
+	// Resources which do not exist should be considered already destroyed.
+	Destroy(ref model.DeploymentRef) error
+}
+
+// Fetcher is implemented by a Deployer which can fetch the definitions of
+// deployments as they are currently deployed, so that they can be compared
+// with the definitions to be deployed.
+type Fetcher interface {
+	// Fetch returns the normalized definition of ref as it is currently
+	// deployed in its framework, or nil if it is not deployed.
+	Fetch(ref model.DeploymentRef) ([]byte, error)
+	// Normalize returns definition, the definition of ref to be deployed, in
+	// the same normalized form as Fetch returns.
+	Normalize(ref model.DeploymentRef, definition []byte) ([]byte, error)
+}
+
+// Identifier is implemented by a Deployer which can identify the deployed
+// resources of a deployment in its framework.
+type Identifier interface {
+	// Identify returns the ID of the resources of ref in its framework, and
+	// their current version if the framework versions them, or an empty
+	// version if they are not deployed.
+	Identify(ref model.DeploymentRef) (id, version string, err error)
+}
+
 // Status indicates the current state of a deployment.
 type Status int32
 
@@ -36,7 +75,8 @@ type Status int32
 //         /                \
 //    StatusHealthy   StatusHealthError
 //
-// It is also possible to enter StatusPanic when a panic occurs in deploy.
+// It is also possible to enter StatusPanic when a panic occurs in deploy, in
+// which case the phase that panicked fails with an error describing it.
 const (
 	StatusNotReady Status = iota
 	StatusReady
@@ -91,6 +131,12 @@ type Deployment struct {
 	healthyMutex sync.Mutex
 	healthyChan  chan struct{}
 	healthyError error
+	existing     bool
+	restart      bool
+	destroy      bool
+	startTime    time.Time
+	deployedTime time.Time
+	healthyTime  time.Time
 }
 
 // Ref returns the DeploymentRef for d.
@@ -98,11 +144,38 @@ func (d *Deployment) Ref() model.DeploymentRef {
 	return d.ref
 }
 
+// Existing returns true if d was already deployed, in which case it only has
+// a health phase.
+func (d *Deployment) Existing() bool {
+	return d.existing
+}
+
+// Restart returns true if d is restarted because one of its dependencies
+// changed, in which case it waits for all of its dependencies to become
+// healthy first.
+func (d *Deployment) Restart() bool {
+	return d.restart
+}
+
+// Destroy returns true if d is being destroyed rather than deployed, in which
+// case its deploy phase removes its resources and its health phase does
+// nothing.
+func (d *Deployment) Destroy() bool {
+	return d.destroy
+}
+
 // Status returns the current state of d.
 func (d *Deployment) Status() Status {
 	return Status(atomic.LoadInt32(&d.status))
 }
 
+// Times returns when d started its deploy phase and completed its deploy and
+// health phases, each of which is zero if it has not happened.
+// Times is only safe to call once the GraphDeployer running d has finished.
+func (d *Deployment) Times() (start, deployed, healthy time.Time) {
+	return d.startTime, d.deployedTime, d.healthyTime
+}
+
 // WaitUntilDeployed blocks until d has completed its deploy phase.
 // If an error occurs in the deploy phase, it is returned here.
 // WaitUntilDeployed can be called any number of times and will return
@@ -148,11 +221,11 @@ func (d *Deployment) cancel(err error) error {
 }
 
 // deploy begins the deployment process, entering the deploy phase and then
-// the health phase if the deploy phase succeeds.
+// the health phase if the deploy phase succeeds, calling deployed in between.
 // deploy can only be called if d is in state StatusReady.
 // If deploy returns no error, d is put in state StatusHealthy.
 // Refer to the state diagram for more detail.
-func (d *Deployment) deploy() error {
+func (d *Deployment) deploy(deployed func()) error {
 	if !d._swapStatus(StatusReady, StatusDeploying) {
 		return fmt.Errorf("deployment is not ready or already started")
 	}
@@ -162,16 +235,20 @@ func (d *Deployment) deploy() error {
 			panic(r)
 		}
 	}()
+	d.startTime = time.Now()
 	err := d._deployPhase()
 	if err != nil {
 		d._setStatus(StatusDeployError)
 		return err
 	}
+	d.deployedTime = time.Now()
+	deployed()
 	d._setStatus(StatusWaitingUntilHealthy)
 	if err := d._healthPhase(); err != nil {
 		d._setStatus(StatusHealthError)
 		return err
 	}
+	d.healthyTime = time.Now()
 	d._setStatus(StatusHealthy)
 	return nil
 }
@@ -193,12 +270,40 @@ func (d *Deployment) ready(deployer Deployer, ref model.DeploymentRef) error {
 	return nil
 }
 
-// _deployPhase is the internal implementation of the deploy phase.
+// _deployPhase is the internal implementation of the deploy phase, which does
+// nothing for an existing deployment, restarts a deployment to be restarted
+// if the deployer supports it, and destroys a deployment to be destroyed.
 func (d *Deployment) _deployPhase() error {
 	defer close(d.deployChan)
 	d.deployMutex.Lock()
 	defer d.deployMutex.Unlock()
-	if err := d.deployer.Deploy(d.ref); err != nil {
+	defer func() {
+		if r := recover(); r != nil {
+			d.deployError = fmt.Errorf("panic: %v", r)
+			panic(r)
+		}
+	}()
+	if d.existing {
+		return nil
+	}
+	deploy := d.deployer.Deploy
+	if restarter, ok := d.deployer.(Restarter); ok && d.restart {
+		deploy = restarter.Restart
+	}
+	if d.destroy {
+		destroyer, ok := d.deployer.(Destroyer)
+		if !ok {
+			d.deployError = fmt.Errorf("deployer does not support destroying deployments")
+			return d.deployError
+		}
+		if err := destroyer.Destroy(d.ref); err != nil {
+			err = fmt.Errorf("failed to destroy in framework: %w", err)
+			d.deployError = err
+			return err
+		}
+		return nil
+	}
+	if err := deploy(d.ref); err != nil {
 		err = fmt.Errorf("failed to deploy to framework: %w", err)
 		d.deployError = err
 		return err
@@ -206,11 +311,21 @@ func (d *Deployment) _deployPhase() error {
 	return nil
 }
 
-// _healthPhase is the internal implementation of the health phase.
+// _healthPhase is the internal implementation of the health phase, which does
+// nothing for a deployment being destroyed.
 func (d *Deployment) _healthPhase() error {
 	defer close(d.healthyChan)
 	d.healthyMutex.Lock()
 	defer d.healthyMutex.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			d.healthyError = fmt.Errorf("panic: %v", r)
+			panic(r)
+		}
+	}()
+	if d.destroy {
+		return nil
+	}
 	if err := d.deployer.WaitUntilHealthy(d.ref); err != nil {
 		err = fmt.Errorf("failed to wait until framework considered deployment healthy: %w", err)
 		d.healthyError = err
@@ -24,8 +24,13 @@ type EventType int32
 //     {EventDependencySuccess}     EventDependencyFailure
 //                 |                        |
 //      EventDeploymentStarted              |
-//     /                      \             |
+//         |              \                 |
+//    EventDeployed        \                |
+//     /          \         \               |
 //    EventDeploymentSuccess   EventDeploymentFailure
+//
+// EventDeployed is sent once the deploy phase of a deployment has succeeded,
+// before its health phase.
 const (
 	EventEnqueued EventType = iota
 	EventDequeued
@@ -34,6 +39,7 @@ const (
 	EventDependencyFailure
 	EventDependencySuccess
 	EventDeploymentStarted
+	EventDeployed
 	EventDeploymentSuccess
 	EventDeploymentFailure
 )
@@ -52,6 +58,8 @@ func (e EventType) String() string {
 		return "EventDependencySuccess"
 	case EventDeploymentStarted:
 		return "EventDeploymentStarted"
+	case EventDeployed:
+		return "EventDeployed"
 	case EventDeploymentSuccess:
 		return "EventDeploymentSuccess"
 	case EventDeploymentFailure:
@@ -78,10 +86,14 @@ type Event struct {
 }
 
 // Stats contains statistics on the results of a deployment.
+// Existing deployments which become healthy are counted in
+// ExistingDeployments rather than SuccessfulDeployments, since they were not
+// deployed.
 type Stats struct {
 	TotalDeployments      int32
 	SuccessfulDeployments int32
 	FailedDeployments     int32
+	ExistingDeployments   int32
 	ElapsedTime           time.Duration
 }
 
@@ -97,6 +109,9 @@ type GraphDeployer struct {
 	waitGroup         sync.WaitGroup
 	deployments       []Deployment
 	deploymentsByRef  map[model.DeploymentRef]*Deployment
+	existing          map[model.DeploymentRef]bool
+	restart           map[model.DeploymentRef]bool
+	destroy           bool
 	eventsChan        chan<- Event
 	errorsChan        chan error
 	stats             Stats
@@ -119,16 +134,64 @@ func NewGraphDeployer(graph *model.Graph, deployer Deployer, maxDeploy int) (*Gr
 	}, nil
 }
 
+// SetExisting marks deployments of the graph which are already deployed, so
+// that they are not deployed again but are still waited on to become healthy
+// by the deployments that depend on them.
+// SetExisting must be called before Deploy.
+func (d *GraphDeployer) SetExisting(refs ...model.DeploymentRef) {
+	if d.existing == nil {
+		d.existing = make(map[model.DeploymentRef]bool, len(refs))
+	}
+	for _, ref := range refs {
+		d.existing[ref] = true
+	}
+}
+
+// SetRestart marks deployments of the graph which are restarted because one
+// of their dependencies changed, using the Restarter interface if the deployer
+// implements it and redeploying them otherwise.
+// They wait for all of their dependencies to become healthy first.
+// SetRestart must be called before Deploy.
+func (d *GraphDeployer) SetRestart(refs ...model.DeploymentRef) {
+	if d.restart == nil {
+		d.restart = make(map[model.DeploymentRef]bool, len(refs))
+	}
+	for _, ref := range refs {
+		d.restart[ref] = true
+	}
+}
+
 // Stats returns statistics on the deployment, which are only meaningful
 // after Deploy has been called.
 func (d *GraphDeployer) Stats() Stats {
 	return d.stats
 }
 
+// Destroy removes every deployment of the graph, blocking until it is
+// complete.
+// It walks the graph in reverse, so that a deployment is only removed once
+// every deployment which depends on it has been removed, but otherwise runs
+// like Deploy, with the same workers and events; in the events, the
+// dependencies of a deployment are the dependents it waits on.
+// Existing and restarted deployments are removed like any other.
+// The deployer must implement Destroyer.
+func (d *GraphDeployer) Destroy(events chan<- Event) error {
+	if _, ok := d.deployer.(Destroyer); !ok {
+		if events != nil {
+			close(events)
+		}
+		return fmt.Errorf("deployer does not support destroying deployments")
+	}
+	d.destroy = true
+	return d.Deploy(events)
+}
+
 // Deploy executes the deployment process, blocking until it is complete.
 // To monitor the status of the deployment, provide a non-nil events channel.
 // Deploy will create a fixed number of worker goroutines to execute the
 // and will wait until they all complete.
+// Each deployment waits only on the dependencies in the transitive reduction
+// of the graph, since the rest are implied.
 func (d *GraphDeployer) Deploy(events chan<- Event) error {
 	d.eventsChan = events
 	defer d.closeEventsChan()
@@ -148,6 +211,28 @@ func (d *GraphDeployer) Deploy(events chan<- Event) error {
 	if err != nil {
 		return fmt.Errorf("resolving deployment order: %w", err)
 	}
+	// dependencies implied by others need not be waited on separately, but
+	// restarted deployments wait for all of their dependencies to become
+	// healthy, so their edges can only be implied by paths which wait
+	if len(d.restart) != 0 {
+		restarted := make([]model.DeploymentRef, 0, len(d.restart))
+		for ref := range d.restart {
+			restarted = append(restarted, ref)
+		}
+		if d.graph, err = d.graph.WaitingFrom(restarted); err != nil {
+			return fmt.Errorf("resolving restarted deployments: %w", err)
+		}
+	}
+	reduced, err := d.graph.TransitiveReduction()
+	if err != nil {
+		return fmt.Errorf("reducing dependency graph: %w", err)
+	}
+	d.graph = reduced
+	if d.destroy {
+		for i, j := 0, len(deployOrder)-1; i < j; i, j = i+1, j-1 {
+			deployOrder[i], deployOrder[j] = deployOrder[j], deployOrder[i]
+		}
+	}
 	d.stats.TotalDeployments = int32(len(deployOrder))
 	d.deployments = make([]Deployment, len(deployOrder))
 	d.deploymentsByRef = make(map[model.DeploymentRef]*Deployment, len(deployOrder))
@@ -156,6 +241,9 @@ func (d *GraphDeployer) Deploy(events chan<- Event) error {
 		if err := deployment.ready(d.deployer, deployRef); err != nil {
 			return fmt.Errorf("readying deployment: %w", err)
 		}
+		deployment.existing = d.existing[deployRef]
+		deployment.restart = d.restart[deployRef]
+		deployment.destroy = d.destroy
 		d.deploymentsByRef[deployRef] = deployment
 	}
 	for i := range d.deployments {
@@ -221,14 +309,22 @@ func (d *GraphDeployer) workerMain(workerID int) {
 			// error came from the deployment anyway
 			_ = deployment.cancel(err)
 			atomic.AddInt32(&d.stats.FailedDeployments, 1)
-			d.sendError(fmt.Errorf("deploying %s.%s: %w", deployRef.Type, deployRef.Name, err))
+			action := "deploying"
+			if d.destroy {
+				action = "destroying"
+			}
+			d.sendError(fmt.Errorf("%s %s.%s: %w", action, deployRef.Type, deployRef.Name, err))
 			d.sendEvent(workerID, Event{
 				Type:       EventDeploymentFailure,
 				Deployment: deployment,
 				Err:        err,
 			})
 		} else {
-			atomic.AddInt32(&d.stats.SuccessfulDeployments, 1)
+			if deployment.existing {
+				atomic.AddInt32(&d.stats.ExistingDeployments, 1)
+			} else {
+				atomic.AddInt32(&d.stats.SuccessfulDeployments, 1)
+			}
 			d.sendEvent(workerID, Event{
 				Type:       EventDeploymentSuccess,
 				Deployment: deployment,
@@ -238,13 +334,30 @@ func (d *GraphDeployer) workerMain(workerID int) {
 	}
 }
 
-func (d *GraphDeployer) workerDeploy(workerID int, deployment *Deployment) error {
+// workerDeploy waits for the dependencies of deployment and then deploys it,
+// returning an error if either fails or if deploying it panics.
+func (d *GraphDeployer) workerDeploy(workerID int, deployment *Deployment) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
 	deployRef := deployment.Ref()
-	// resolve dependencies
+	// resolve dependencies, which are the dependents when destroying
 	dependRefs, err := d.graph.Dependencies(deployRef)
 	if err != nil {
 		return fmt.Errorf("cannot resolve dependencies: %w", err)
 	}
+	if d.destroy {
+		edges, err := d.graph.Dependents(deployRef)
+		if err != nil {
+			return fmt.Errorf("cannot resolve dependents: %w", err)
+		}
+		dependRefs = make([]model.DependencyRef, len(edges))
+		for i, edge := range edges {
+			dependRefs[i] = model.DependencyRef{Type: edge.From.Type, Name: edge.From.Name}
+		}
+	}
 	// map dependencies to their deployments
 	var dependencies []Dependency
 	if len(dependRefs) != 0 {
@@ -254,9 +367,12 @@ func (d *GraphDeployer) workerDeploy(workerID int, deployment *Deployment) error
 			if !ok {
 				return fmt.Errorf("no deployment exists for dependency %s.%s", dependRef.Type, dependRef.Name)
 			}
+			if deployment.restart {
+				dependRefs[i].WaitForHealthy = true
+			}
 			dependencies[i] = Dependency{
 				Deployment:     dependency,
-				WaitForHealthy: dependRef.WaitForHealthy,
+				WaitForHealthy: dependRefs[i].WaitForHealthy,
 			}
 		}
 	}
@@ -275,6 +391,9 @@ func (d *GraphDeployer) workerDeploy(workerID int, deployment *Deployment) error
 				Dependency: dependency,
 				Err:        err,
 			})
+			if d.destroy {
+				return fmt.Errorf("dependent %s.%s failed to be destroyed: %w", dependRef.Type, dependRef.Name, err)
+			}
 			return fmt.Errorf("dependency %s.%s failed to deploy: %w", dependRef.Type, dependRef.Name, err)
 		}
 		if dependRef.WaitForHealthy {
@@ -301,5 +420,10 @@ func (d *GraphDeployer) workerDeploy(workerID int, deployment *Deployment) error
 		Type:       EventDeploymentStarted,
 		Deployment: deployment,
 	})
-	return deployment.deploy()
+	return deployment.deploy(func() {
+		d.sendEvent(workerID, Event{
+			Type:       EventDeployed,
+			Deployment: deployment,
+		})
+	})
 }
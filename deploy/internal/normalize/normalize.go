@@ -0,0 +1,185 @@
+// Package normalize puts deployment definitions into a canonical form, so
+// that the definition to be deployed can be compared with the one a framework
+// reports.
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rules describe how to normalize the definitions of a framework.
+// Fields are given by paths of field names separated by dots, where a name
+// ending in "[]" is an array whose elements each have the rest of the path,
+// such as "healthChecks[].intervalSeconds".
+type Rules struct {
+	// Convert, if not nil, rewrites deprecated fields of a definition into
+	// the fields the framework reports them as, before the other rules are
+	// applied.
+	Convert func(doc map[string]interface{})
+	// Defaults holds the values the framework gives to fields which are not
+	// set, by path.
+	// Defaults are applied in the order of their paths, so a default for an
+	// object is applied before the defaults for its fields; objects which
+	// are not set are not otherwise created.
+	Defaults map[string]interface{}
+	// ServerFields lists the fields which are set by the framework rather
+	// than by the definition, such as versions and task counts.
+	ServerFields []string
+	// Unordered lists the array fields whose order does not matter.
+	Unordered []string
+}
+
+// Apply returns content normalized by r: deprecated fields are converted,
+// server fields are removed, missing fields are set to their defaults,
+// unordered arrays are sorted, and fields which are null, empty objects, or
+// empty arrays are removed, at any depth, both before and after defaults are
+// applied so that an empty field is treated as missing.
+// The result is compact JSON with sorted object keys.
+func (r *Rules) Apply(content []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("decoding definition: %w", err)
+	} else if doc == nil {
+		return nil, fmt.Errorf("definition is not an object")
+	}
+	if r.Convert != nil {
+		r.Convert(doc)
+	}
+	for _, path := range r.ServerFields {
+		visit(doc, splitPath(path), func(parent map[string]interface{}, field string) {
+			delete(parent, field)
+		})
+	}
+	if prune(doc) == nil {
+		doc = make(map[string]interface{})
+	}
+	paths := make([]string, 0, len(r.Defaults))
+	for path := range r.Defaults {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		value := r.Defaults[path]
+		visit(doc, splitPath(path), func(parent map[string]interface{}, field string) {
+			if _, ok := parent[field]; !ok {
+				parent[field] = deepCopy(value)
+			}
+		})
+	}
+	for _, path := range r.Unordered {
+		visit(doc, splitPath(path), func(parent map[string]interface{}, field string) {
+			if array, ok := parent[field].([]interface{}); ok {
+				sortArray(array)
+			}
+		})
+	}
+	return json.Marshal(prune(doc))
+}
+
+// splitPath splits a path into its field names.
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// visit calls fn with each object which has the last field of path and that
+// field, whether or not it is set, skipping objects and array elements which
+// are missing or of the wrong type.
+func visit(value interface{}, path []string, fn func(parent map[string]interface{}, field string)) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	field := path[0]
+	array := strings.HasSuffix(field, "[]")
+	field = strings.TrimSuffix(field, "[]")
+	if len(path) == 1 && !array {
+		fn(object, field)
+		return
+	}
+	next, ok := object[field]
+	if !ok {
+		return
+	}
+	if !array {
+		visit(next, path[1:], fn)
+		return
+	}
+	elements, _ := next.([]interface{})
+	for _, element := range elements {
+		if len(path) > 1 {
+			visit(element, path[1:], fn)
+		}
+	}
+}
+
+// deepCopy returns a copy of a decoded JSON value which shares no objects or
+// arrays with it.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			copied[key] = deepCopy(field)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, element := range v {
+			copied[i] = deepCopy(element)
+		}
+		return copied
+	}
+	return value
+}
+
+// sortArray sorts the elements of array by their JSON encoding.
+func sortArray(array []interface{}) {
+	keys := make(map[int]string, len(array))
+	indexes := make([]int, len(array))
+	for i, element := range array {
+		encoded, _ := json.Marshal(element)
+		keys[i] = string(encoded)
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return keys[indexes[i]] < keys[indexes[j]]
+	})
+	sorted := make([]interface{}, len(array))
+	for i, index := range indexes {
+		sorted[i] = array[index]
+	}
+	copy(array, sorted)
+}
+
+// prune removes null values, empty objects, and empty arrays from value,
+// returning nil if value itself is one of them.
+// Array elements are emptied but not removed.
+func prune(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if pruned := prune(field); pruned == nil {
+				delete(v, key)
+			} else {
+				v[key] = pruned
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		// an array element keeps its place even when it is emptied
+		for i := range v {
+			if pruned := prune(v[i]); pruned != nil {
+				v[i] = pruned
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package normalize
+
+import (
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	rules := Rules{
+		Convert: func(doc map[string]interface{}) {
+			if old, ok := doc["old"]; ok {
+				doc["new"] = old
+				delete(doc, "old")
+			}
+		},
+		Defaults: map[string]interface{}{
+			"count":               1.0,
+			"name":                "",
+			"strategy":            map[string]interface{}{"kind": "rolling"},
+			"strategy.capacity":   1.0,
+			"checks[].interval":   60.0,
+			"checks[].port.index": 0.0,
+			"missing.field":       true,
+		},
+		ServerFields: []string{"version", "checks[].status"},
+		Unordered:    []string{"tags", "checks[].hosts"},
+	}
+	cases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "defaults",
+			content: `{}`,
+			want:    `{"count":1,"name":"","strategy":{"capacity":1,"kind":"rolling"}}`,
+		},
+		{
+			name:    "set fields kept",
+			content: `{"count":3,"name":"web","strategy":{"capacity":0.5}}`,
+			want:    `{"count":3,"name":"web","strategy":{"capacity":0.5}}`,
+		},
+		{
+			name:    "empty fields treated as missing",
+			content: `{"count":null,"strategy":{},"tags":[],"labels":{"a":null}}`,
+			want:    `{"count":1,"name":"","strategy":{"capacity":1,"kind":"rolling"}}`,
+		},
+		{
+			name:    "server fields removed",
+			content: `{"version":"2020-05-04","checks":[{"interval":5,"status":"ok"}]}`,
+			want:    `{"checks":[{"interval":5}],"count":1,"name":"","strategy":{"capacity":1,"kind":"rolling"}}`,
+		},
+		{
+			name:    "array element defaults",
+			content: `{"checks":[{"port":{}},{"interval":5,"port":{"index":2}}]}`,
+			want:    `{"checks":[{"interval":60},{"interval":5,"port":{"index":2}}],"count":1,"name":"","strategy":{"capacity":1,"kind":"rolling"}}`,
+		},
+		{
+			name:    "unordered arrays sorted",
+			content: `{"tags":["b","a"],"checks":[{"hosts":[{"h":2},{"h":1}]}]}`,
+			want:    `{"checks":[{"hosts":[{"h":1},{"h":2}],"interval":60}],"count":1,"name":"","strategy":{"capacity":1,"kind":"rolling"},"tags":["a","b"]}`,
+		},
+		{
+			name:    "converted",
+			content: `{"old":"value"}`,
+			want:    `{"count":1,"name":"","new":"value","strategy":{"capacity":1,"kind":"rolling"}}`,
+		},
+		{
+			name:    "wrong types skipped",
+			content: `{"strategy":"disabled","checks":{"interval":5}}`,
+			want:    `{"checks":{"interval":5},"count":1,"name":"","strategy":"disabled"}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := rules.Apply([]byte(c.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.want {
+				t.Errorf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyDefaultsNotShared(t *testing.T) {
+	rules := Rules{
+		Defaults: map[string]interface{}{
+			"strategy":          map[string]interface{}{},
+			"strategy.capacity": 1.0,
+		},
+	}
+	if _, err := rules.Apply([]byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Defaults["strategy"].(map[string]interface{})) != 0 {
+		t.Errorf("default object was modified: %v", rules.Defaults["strategy"])
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	var rules Rules
+	for _, content := range []string{`[]`, `null`, `{`} {
+		if _, err := rules.Apply([]byte(content)); err == nil {
+			t.Errorf("%s: no error", content)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package rest sends JSON requests to the REST APIs of Mesos frameworks.
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StatusError is returned by Client.Do when a framework responds with a
+// status other than 2xx.
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.URL, e.Code, http.StatusText(e.Code), e.Body)
+}
+
+// IsNotFound returns true if err is a StatusError with status 404.
+func IsNotFound(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	return ok && statusErr.Code == http.StatusNotFound
+}
+
+// Client sends requests to a framework, trying each of its masters in turn
+// until one of them can be reached.
+// Exposed methods are safe to use from multiple concurrent goroutines.
+type Client struct {
+	masters []string
+	http    *http.Client
+}
+
+// NewClient creates a Client for a framework with the given masters, which
+// are host:port pairs or base URLs, that gives up on each request after
+// timeout.
+func NewClient(masters []string, timeout time.Duration) *Client {
+	c := &Client{
+		masters: make([]string, len(masters)),
+		http:    &http.Client{Timeout: timeout},
+	}
+	for i, master := range masters {
+		if !strings.Contains(master, "://") {
+			master = "http://" + master
+		}
+		c.masters[i] = strings.TrimSuffix(master, "/")
+	}
+	return c
+}
+
+// Do sends a request with the given method, path, and JSON body, which may be
+// nil, and decodes the JSON response into out, unless it is nil.
+// The request is sent to the next master only if the previous one could not
+// be connected to or, for a GET request, did not respond, since other
+// requests may have taken effect even if no response was received.
+// If no master can be reached, the error from the last one is returned.
+func (c *Client) Do(method, path string, body []byte, out interface{}) error {
+	if len(c.masters) == 0 {
+		return fmt.Errorf("no masters to send %s %s to", method, path)
+	}
+	var err error
+	for _, master := range c.masters {
+		var response []byte
+		if response, err = c.send(method, master+path, body); err != nil {
+			if _, ok := err.(*StatusError); ok {
+				return err
+			} else if method != http.MethodGet && !isDialError(err) {
+				return err
+			}
+			continue
+		}
+		if out == nil || len(response) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(response, out); err != nil {
+			return fmt.Errorf("decoding response to %s %s: %w", method, master+path, err)
+		}
+		return nil
+	}
+	return err
+}
+
+// isDialError returns true if err shows that a request could not be sent
+// because no connection could be made.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// send sends a single request and returns the body of a 2xx response.
+func (c *Client) send(method, url string, body []byte) ([]byte, error) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response to %s %s: %w", method, url, err)
+	}
+	if response.StatusCode/100 != 2 {
+		return nil, &StatusError{
+			Method: method,
+			URL:    url,
+			Code:   response.StatusCode,
+			Body:   strings.TrimSpace(string(content)),
+		}
+	}
+	return content, nil
+}
+
+// Poll calls check every interval until it returns true or an error, or
+// until timeout has passed since Poll was called, if timeout is positive.
+func Poll(interval, timeout time.Duration, check func() (bool, error)) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		} else if done {
+			return nil
+		} else if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
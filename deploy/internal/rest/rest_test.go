@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closedAddress returns the address of a port which refuses connections.
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+// testServer returns a server which counts its requests in count and
+// responds to them with status, after delay.
+func testServer(t *testing.T, status int, delay time.Duration, count *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id": "/app"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// hangUpServer returns a server which counts its requests in count and
+// closes their connections without responding, after reading them.
+func hangUpServer(t *testing.T, count *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientDoFailover(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		firstStatus int
+		firstDelay  time.Duration
+		firstDown   bool
+		firstHangUp bool
+		// first and second are the requests each master should receive
+		first, second int32
+		err           bool
+	}{
+		{name: "first responds", method: "PUT", firstStatus: 200, first: 1},
+		{name: "first refuses POST", method: "POST", firstDown: true, second: 1},
+		{name: "first refuses GET", method: "GET", firstDown: true, second: 1},
+		{name: "first times out on PUT", method: "PUT", firstStatus: 200, firstDelay: 300 * time.Millisecond, first: 1,
+			err: true},
+		{name: "first times out on DELETE", method: "DELETE", firstStatus: 200, firstDelay: 300 * time.Millisecond,
+			first: 1, err: true},
+		{name: "first times out on GET", method: "GET", firstStatus: 200, firstDelay: 300 * time.Millisecond, first: 1,
+			second: 1},
+		{name: "first hangs up on POST", method: "POST", firstHangUp: true, first: 1, err: true},
+		{name: "first hangs up on PUT", method: "PUT", firstHangUp: true, first: 1, err: true},
+		{name: "first hangs up on GET", method: "GET", firstHangUp: true, first: 1, second: 1},
+		{name: "first fails", method: "GET", firstStatus: 500, first: 1, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var first, second int32
+			var firstAddress string
+			if test.firstDown {
+				firstAddress = closedAddress(t)
+			} else if test.firstHangUp {
+				firstAddress = hangUpServer(t, &first).URL
+			} else {
+				firstAddress = testServer(t, test.firstStatus, test.firstDelay, &first).URL
+			}
+			secondAddress := testServer(t, 200, 0, &second).URL
+			client := NewClient([]string{firstAddress, secondAddress}, 100*time.Millisecond)
+			var out struct{ ID string }
+			err := client.Do(test.method, "/v2/apps/app", []byte(`{}`), &out)
+			if test.err && err == nil {
+				t.Error("expected error")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !test.err && out.ID != "/app" {
+				t.Errorf("got ID %q, want /app", out.ID)
+			}
+			if a, b := atomic.LoadInt32(&first), atomic.LoadInt32(&second); a != test.first || b != test.second {
+				t.Errorf("masters got %d and %d requests, want %d and %d", a, b, test.first, test.second)
+			}
+		})
+	}
+}
+
+func TestIsDialError(t *testing.T) {
+	client := NewClient([]string{closedAddress(t)}, time.Second)
+	if _, err := client.send("POST", client.masters[0]+"/v2/apps", nil); !isDialError(err) {
+		t.Errorf("got %v for a refused connection, want a dial error", err)
+	}
+	var count int32
+	server := hangUpServer(t, &count)
+	if _, err := client.send("POST", server.URL+"/v2/apps", nil); err == nil || isDialError(err) {
+		t.Errorf("got %v for a closed connection, want an error other than a dial error", err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	var count int32
+	server := testServer(t, 404, 0, &count)
+	err := NewClient([]string{server.URL}, time.Second).Do("DELETE", "/v2/apps/app", nil, nil)
+	if !IsNotFound(err) {
+		t.Errorf("got %v, want not found", err)
+	}
+}
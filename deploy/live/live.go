@@ -0,0 +1,164 @@
+// Package live provides a Deployer which deploys to the frameworks declared
+// by a configuration, passing each deployment to the Marathon or Chronos
+// deployer for its framework.
+package live
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/deploy/chronos"
+	"github.com/kbolino/mesosdef/deploy/marathon"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Deployer is a deploy.Deployer which deploys each deployment to its
+// framework.
+type Deployer struct {
+	deployers map[model.DeploymentRef]deploy.Deployer
+}
+
+var (
+	_ deploy.Deployer   = &Deployer{}
+	_ deploy.Restarter  = &Deployer{}
+	_ deploy.Destroyer  = &Deployer{}
+	_ deploy.Fetcher    = &Deployer{}
+	_ deploy.Identifier = &Deployer{}
+)
+
+// New creates a Deployer for the deployments and frameworks of root, which
+// must have been validated, loading definitions from source.
+// Each request to a framework gives up after requestTimeout, and waiting for
+// a deployment to complete or become healthy gives up after waitTimeout.
+func New(root *model.Root, source definition.Source, requestTimeout, waitTimeout time.Duration) (*Deployer,
+	error) {
+	frameworks := make(map[model.FrameworkRef]deploy.Deployer, len(root.Frameworks))
+	for i := range root.Frameworks {
+		framework := &root.Frameworks[i]
+		switch framework.Type {
+		case "marathon":
+			frameworks[framework.Ref()] = marathon.New(framework.Masters, source, requestTimeout, waitTimeout)
+		case "chronos":
+			frameworks[framework.Ref()] = chronos.New(framework.Masters, source, requestTimeout)
+		default:
+			return nil, fmt.Errorf("framework %s.%s has unsupported type", framework.Type, framework.Name)
+		}
+	}
+	d := &Deployer{
+		deployers: make(map[model.DeploymentRef]deploy.Deployer, len(root.Deployments)),
+	}
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		frameworkRef, ok := deployment.FrameworkRef()
+		if !ok {
+			return nil, fmt.Errorf("deployment %s.%s has unsupported type", deployment.Type, deployment.Name)
+		}
+		deployer, ok := frameworks[frameworkRef]
+		if !ok {
+			return nil, fmt.Errorf("framework %s.%s of deployment %s.%s not found", frameworkRef.Type,
+				frameworkRef.Name, deployment.Type, deployment.Name)
+		}
+		d.deployers[deployment.Ref()] = deployer
+	}
+	return d, nil
+}
+
+// Deploy deploys ref to its framework.
+func (d *Deployer) Deploy(ref model.DeploymentRef) error {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return err
+	}
+	return deployer.Deploy(ref)
+}
+
+// Restart restarts ref if its framework supports it, and redeploys it
+// otherwise.
+func (d *Deployer) Restart(ref model.DeploymentRef) error {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return err
+	}
+	if restarter, ok := deployer.(deploy.Restarter); ok {
+		return restarter.Restart(ref)
+	}
+	return deployer.Deploy(ref)
+}
+
+// Destroy removes ref from its framework.
+func (d *Deployer) Destroy(ref model.DeploymentRef) error {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return err
+	}
+	destroyer, ok := deployer.(deploy.Destroyer)
+	if !ok {
+		return fmt.Errorf("framework of %s.%s does not support destroying deployments", ref.Type, ref.Name)
+	}
+	return destroyer.Destroy(ref)
+}
+
+// Fetch returns the definition of ref as deployed in its framework.
+func (d *Deployer) Fetch(ref model.DeploymentRef) ([]byte, error) {
+	fetcher, err := d.fetcher(ref)
+	if err != nil {
+		return nil, err
+	}
+	return fetcher.Fetch(ref)
+}
+
+// Normalize normalizes definition, the definition of ref, for its framework.
+func (d *Deployer) Normalize(ref model.DeploymentRef, definition []byte) ([]byte, error) {
+	fetcher, err := d.fetcher(ref)
+	if err != nil {
+		return nil, err
+	}
+	return fetcher.Normalize(ref, definition)
+}
+
+// Identify identifies ref in its framework.
+func (d *Deployer) Identify(ref model.DeploymentRef) (string, string, error) {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return "", "", err
+	}
+	identifier, ok := deployer.(deploy.Identifier)
+	if !ok {
+		return "", "", fmt.Errorf("framework of %s.%s does not support identifying deployments", ref.Type,
+			ref.Name)
+	}
+	return identifier.Identify(ref)
+}
+
+// WaitUntilHealthy waits until ref is healthy in its framework.
+func (d *Deployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return err
+	}
+	return deployer.WaitUntilHealthy(ref)
+}
+
+// fetcher returns the deployer for the framework of ref as a Fetcher.
+func (d *Deployer) fetcher(ref model.DeploymentRef) (deploy.Fetcher, error) {
+	deployer, err := d.deployer(ref)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, ok := deployer.(deploy.Fetcher)
+	if !ok {
+		return nil, fmt.Errorf("framework of %s.%s does not support fetching deployments", ref.Type, ref.Name)
+	}
+	return fetcher, nil
+}
+
+// deployer returns the deployer for the framework of ref.
+func (d *Deployer) deployer(ref model.DeploymentRef) (deploy.Deployer, error) {
+	deployer, ok := d.deployers[ref]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not found", ref.Type, ref.Name)
+	}
+	return deployer, nil
+}
@@ -0,0 +1,241 @@
+// Package marathon provides a Deployer which deploys apps to Marathon through
+// its REST API.
+package marathon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/deploy/internal/rest"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// pollInterval is how often Marathon is asked whether a deployment is
+// complete or an app is healthy.
+const pollInterval = 2 * time.Second
+
+// Deployer is a deploy.Deployer which deploys the definitions of marathon_app
+// deployments as Marathon apps.
+type Deployer struct {
+	client      *rest.Client
+	source      definition.Source
+	waitTimeout time.Duration
+}
+
+var (
+	_ deploy.Deployer   = &Deployer{}
+	_ deploy.Restarter  = &Deployer{}
+	_ deploy.Destroyer  = &Deployer{}
+	_ deploy.Fetcher    = &Deployer{}
+	_ deploy.Identifier = &Deployer{}
+)
+
+// New creates a Deployer for the Marathon framework with the given masters,
+// loading definitions from source.
+// Each request gives up after requestTimeout, and waiting for an app to be
+// deployed or become healthy gives up after waitTimeout.
+func New(masters []string, source definition.Source, requestTimeout, waitTimeout time.Duration) *Deployer {
+	return &Deployer{
+		client:      rest.NewClient(masters, requestTimeout),
+		source:      source,
+		waitTimeout: waitTimeout,
+	}
+}
+
+// deploymentResult is the response to a request which starts a Marathon
+// deployment.
+type deploymentResult struct {
+	DeploymentID string `json:"deploymentId"`
+	Version      string `json:"version"`
+}
+
+// Deploy creates or replaces the app of ref and waits until Marathon has
+// finished deploying it.
+func (d *Deployer) Deploy(ref model.DeploymentRef) error {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return err
+	}
+	id, err := AppID(content)
+	if err != nil {
+		return fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	var result deploymentResult
+	if err := d.client.Do("PUT", appPath(id)+"?force=true", content, &result); err != nil {
+		return err
+	}
+	return d.waitForDeployment(result.DeploymentID)
+}
+
+// Restart restarts the tasks of the app of ref and waits until Marathon has
+// finished restarting them.
+func (d *Deployer) Restart(ref model.DeploymentRef) error {
+	id, err := d.appID(ref)
+	if err != nil {
+		return err
+	}
+	var result deploymentResult
+	if err := d.client.Do("POST", appPath(id)+"/restart?force=true", nil, &result); err != nil {
+		return err
+	}
+	return d.waitForDeployment(result.DeploymentID)
+}
+
+// Destroy deletes the app of ref and waits until Marathon has finished
+// removing it.
+// An app which does not exist is considered already destroyed.
+func (d *Deployer) Destroy(ref model.DeploymentRef) error {
+	id, err := d.appID(ref)
+	if err != nil {
+		return err
+	}
+	var result deploymentResult
+	if err := d.client.Do("DELETE", appPath(id)+"?force=true", nil, &result); rest.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return d.waitForDeployment(result.DeploymentID)
+}
+
+// WaitUntilHealthy waits until every instance of the app of ref is healthy,
+// or is running if the app has no health checks.
+func (d *Deployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	id, err := d.appID(ref)
+	if err != nil {
+		return err
+	}
+	return rest.Poll(pollInterval, d.waitTimeout, func() (bool, error) {
+		var response struct {
+			App struct {
+				Instances    int               `json:"instances"`
+				TasksRunning int               `json:"tasksRunning"`
+				TasksHealthy int               `json:"tasksHealthy"`
+				HealthChecks []json.RawMessage `json:"healthChecks"`
+				Deployments  []json.RawMessage `json:"deployments"`
+			} `json:"app"`
+		}
+		if err := d.client.Do("GET", appPath(id), nil, &response); err != nil {
+			return false, err
+		}
+		app := &response.App
+		if len(app.Deployments) != 0 {
+			return false, nil
+		} else if len(app.HealthChecks) == 0 {
+			return app.TasksRunning >= app.Instances, nil
+		}
+		return app.TasksHealthy >= app.Instances, nil
+	})
+}
+
+// Fetch returns the normalized definition of the app of ref as Marathon
+// reports it, or nil if there is no such app.
+// Ports which Marathon assigned because the definition of ref left them to it
+// are reported as zero.
+func (d *Deployer) Fetch(ref model.DeploymentRef) ([]byte, error) {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	id, err := AppID(content)
+	if err != nil {
+		return nil, fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	var response struct {
+		App json.RawMessage `json:"app"`
+	}
+	if err := d.client.Do("GET", appPath(id), nil, &response); rest.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return normalizeLive(response.App, content)
+}
+
+// Identify returns the app ID of ref and the version of the app, if it
+// exists.
+func (d *Deployer) Identify(ref model.DeploymentRef) (string, string, error) {
+	id, err := d.appID(ref)
+	if err != nil {
+		return "", "", err
+	}
+	var response struct {
+		App struct {
+			Version string `json:"version"`
+		} `json:"app"`
+	}
+	if err := d.client.Do("GET", appPath(id), nil, &response); rest.IsNotFound(err) {
+		return id, "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	return id, response.App.Version, nil
+}
+
+// Normalize returns the normalized form of definition, the definition of ref.
+func (d *Deployer) Normalize(ref model.DeploymentRef, definition []byte) ([]byte, error) {
+	return Normalize(definition)
+}
+
+// appID returns the app ID from the definition of ref.
+func (d *Deployer) appID(ref model.DeploymentRef) (string, error) {
+	content, err := d.source.Load(ref)
+	if err != nil {
+		return "", err
+	}
+	id, err := AppID(content)
+	if err != nil {
+		return "", fmt.Errorf("definition of %s.%s: %w", ref.Type, ref.Name, err)
+	}
+	return id, nil
+}
+
+// waitForDeployment waits until the Marathon deployment with the given ID is
+// no longer in progress.
+func (d *Deployer) waitForDeployment(deploymentID string) error {
+	if deploymentID == "" {
+		return nil
+	}
+	return rest.Poll(pollInterval, d.waitTimeout, func() (bool, error) {
+		var deployments []struct {
+			ID string `json:"id"`
+		}
+		if err := d.client.Do("GET", "/v2/deployments", nil, &deployments); err != nil {
+			return false, err
+		}
+		for _, deployment := range deployments {
+			if deployment.ID == deploymentID {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// AppID returns the absolute app ID given by the id field of a Marathon app
+// definition.
+func AppID(content []byte) (string, error) {
+	var app struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(content, &app); err != nil {
+		return "", err
+	} else if app.ID == "" {
+		return "", fmt.Errorf("app has no id")
+	}
+	return "/" + strings.Trim(app.ID, "/"), nil
+}
+
+// appPath returns the path of the app with the given ID in the REST API.
+func appPath(id string) string {
+	segments := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/v2/apps/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,182 @@
+package marathon
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/kbolino/mesosdef/deploy/internal/normalize"
+)
+
+// rules normalizes app definitions, with the defaults given by the Marathon
+// 1.5 API.
+var rules = normalize.Rules{
+	Convert: convertApp,
+	Defaults: map[string]interface{}{
+		"backoffFactor":                         1.15,
+		"backoffSeconds":                        1.0,
+		"cpus":                                  1.0,
+		"disk":                                  0.0,
+		"executor":                              "",
+		"gpus":                                  0.0,
+		"instances":                             1.0,
+		"killSelection":                         "YOUNGEST_FIRST",
+		"maxLaunchDelaySeconds":                 3600.0,
+		"mem":                                   128.0,
+		"networks":                              []interface{}{map[string]interface{}{"mode": "host"}},
+		"requirePorts":                          false,
+		"upgradeStrategy":                       map[string]interface{}{},
+		"upgradeStrategy.minimumHealthCapacity": 1.0,
+		"upgradeStrategy.maximumOverCapacity":   1.0,
+		"unreachableStrategy":                   map[string]interface{}{},
+		"unreachableStrategy.inactiveAfterSeconds": 0.0,
+		"unreachableStrategy.expungeAfterSeconds":  0.0,
+		// an app without ports or networking of its own gets a single port
+		"portDefinitions": []interface{}{
+			map[string]interface{}{"port": 0.0, "name": "default"},
+		},
+		"portDefinitions[].protocol":             "tcp",
+		"container.type":                         "DOCKER",
+		"container.docker.forcePullImage":        false,
+		"container.docker.privileged":            false,
+		"container.portMappings[].containerPort": 0.0,
+		"container.portMappings[].hostPort":      0.0,
+		"container.portMappings[].protocol":      "tcp",
+		"fetch[].cache":                          false,
+		"fetch[].executable":                     false,
+		"fetch[].extract":                        true,
+		"healthChecks[].delaySeconds":            15.0,
+		"healthChecks[].gracePeriodSeconds":      300.0,
+		"healthChecks[].ignoreHttp1xx":           false,
+		"healthChecks[].intervalSeconds":         60.0,
+		"healthChecks[].maxConsecutiveFailures":  3.0,
+		"healthChecks[].protocol":                "HTTP",
+		"healthChecks[].timeoutSeconds":          20.0,
+	},
+	ServerFields: []string{
+		"deployments", "lastTaskFailure", "readinessCheckResults", "tasks", "tasksHealthy", "tasksRunning",
+		"tasksStaged", "tasksUnhealthy", "version", "versionInfo",
+	},
+	Unordered: []string{"acceptedResourceRoles", "constraints", "dependencies", "fetch", "storeUrls"},
+}
+
+// archiveExtensions are the extensions of URIs which Marathon extracts when
+// converting uris to fetch.
+var archiveExtensions = []string{".tgz", ".tar.gz", ".tbz2", ".tar.bz2", ".txz", ".tar.xz", ".zip"}
+
+// convertApp rewrites the deprecated fields of an app the way Marathon does:
+// uris become fetch, ports become portDefinitions, and the Docker network
+// becomes networks.
+func convertApp(app map[string]interface{}) {
+	if uris, ok := app["uris"].([]interface{}); ok {
+		if _, ok := app["fetch"]; !ok {
+			fetch := make([]interface{}, 0, len(uris))
+			for _, uri := range uris {
+				s, _ := uri.(string)
+				extract := false
+				for _, extension := range archiveExtensions {
+					if strings.HasSuffix(s, extension) {
+						extract = true
+					}
+				}
+				fetch = append(fetch, map[string]interface{}{"uri": uri, "extract": extract})
+			}
+			app["fetch"] = fetch
+		}
+	}
+	delete(app, "uris")
+	if ports, ok := app["ports"].([]interface{}); ok {
+		if _, ok := app["portDefinitions"]; !ok {
+			definitions := make([]interface{}, 0, len(ports))
+			for _, port := range ports {
+				definitions = append(definitions, map[string]interface{}{"port": port})
+			}
+			app["portDefinitions"] = definitions
+		}
+	}
+	delete(app, "ports")
+	container, _ := app["container"].(map[string]interface{})
+	docker, _ := container["docker"].(map[string]interface{})
+	if network, ok := docker["network"].(string); ok {
+		if _, ok := app["networks"]; !ok {
+			mode := map[string]string{"BRIDGE": "container/bridge", "HOST": "host", "USER": "container"}[network]
+			app["networks"] = []interface{}{map[string]interface{}{"mode": mode}}
+		}
+		delete(docker, "network")
+	}
+	if mappings, ok := docker["portMappings"]; ok {
+		if _, ok := container["portMappings"]; !ok {
+			container["portMappings"] = mappings
+		}
+		delete(docker, "portMappings")
+	}
+}
+
+// Normalize returns an app definition with the fields Marathon sets removed,
+// defaults applied, the order of unordered arrays fixed, and an absolute id,
+// so that the definition of an app can be compared with the one Marathon
+// reports.
+func Normalize(content []byte) ([]byte, error) {
+	id, err := AppID(content)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := rules.Apply(content)
+	if err != nil {
+		return nil, err
+	}
+	var app map[string]interface{}
+	if err := json.Unmarshal(normalized, &app); err != nil {
+		return nil, err
+	}
+	app["id"] = id
+	return json.Marshal(app)
+}
+
+// normalizeLive returns the normalized form of live, an app as Marathon
+// reports it, where the ports Marathon assigned because definition left them
+// zero or unset are zero again, so that they compare equal.
+func normalizeLive(live, definition []byte) ([]byte, error) {
+	var liveApp, app map[string]interface{}
+	if err := json.Unmarshal(live, &liveApp); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definition, &app); err != nil {
+		return nil, err
+	}
+	// compare the ports each side ends up with after conversion
+	convertApp(liveApp)
+	convertApp(app)
+	if requirePorts, _ := app["requirePorts"].(bool); !requirePorts {
+		clearAssigned(liveApp["portDefinitions"], app["portDefinitions"], "port")
+	}
+	liveContainer, _ := liveApp["container"].(map[string]interface{})
+	container, _ := app["container"].(map[string]interface{})
+	clearAssigned(liveContainer["portMappings"], container["portMappings"], "servicePort")
+	content, err := json.Marshal(liveApp)
+	if err != nil {
+		return nil, err
+	}
+	return Normalize(content)
+}
+
+// clearAssigned sets field to zero in each element of the array live for
+// which the element at the same index of the array defined is zero or unset,
+// or, if defined is unset, in every element.
+func clearAssigned(live, defined interface{}, field string) {
+	liveElements, _ := live.([]interface{})
+	definedElements, definedOK := defined.([]interface{})
+	for i, element := range liveElements {
+		liveElement, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if definedOK && i < len(definedElements) {
+			if definedElement, ok := definedElements[i].(map[string]interface{}); ok {
+				if value, ok := definedElement[field].(float64); ok && value != 0 {
+					continue
+				}
+			}
+		}
+		liveElement[field] = 0.0
+	}
+}
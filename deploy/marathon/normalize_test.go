@@ -0,0 +1,128 @@
+package marathon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/diff"
+)
+
+// testApp returns the definition in testdata/app.json with edit applied, and
+// the app as Marathon reports it for that definition in
+// testdata/app-response.json.
+func testApp(t *testing.T, edit func(app map[string]interface{})) ([]byte, []byte) {
+	t.Helper()
+	content, err := ioutil.ReadFile("testdata/app.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var app map[string]interface{}
+	if err := json.Unmarshal(content, &app); err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(app)
+	}
+	definition, err := json.Marshal(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = ioutil.ReadFile("testdata/app-response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		App json.RawMessage `json:"app"`
+	}
+	if err := json.Unmarshal(content, &response); err != nil {
+		t.Fatal(err)
+	}
+	return definition, response.App
+}
+
+func TestNormalizeLive(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(app map[string]interface{})
+		want string
+	}{
+		{
+			name: "unchanged",
+		},
+		{
+			name: "defaults written out",
+			edit: func(app map[string]interface{}) {
+				app["disk"] = 0
+				app["executor"] = ""
+				app["networks"] = []interface{}{map[string]interface{}{"mode": "host"}}
+				app["healthChecks"].([]interface{})[0].(map[string]interface{})["intervalSeconds"] = 60
+			},
+		},
+		{
+			name: "fetch instead of uris",
+			edit: func(app map[string]interface{}) {
+				delete(app, "uris")
+				app["fetch"] = []interface{}{
+					map[string]interface{}{"uri": "https://example.com/config.json", "extract": false},
+					map[string]interface{}{"uri": "https://example.com/site.tar.gz"},
+				}
+			},
+		},
+		{
+			name: "port definitions instead of ports",
+			edit: func(app map[string]interface{}) {
+				delete(app, "ports")
+				app["portDefinitions"] = []interface{}{
+					map[string]interface{}{"port": 0, "protocol": "tcp"},
+					map[string]interface{}{"port": 0},
+				}
+			},
+		},
+		{
+			name: "fixed port",
+			edit: func(app map[string]interface{}) {
+				app["ports"] = []interface{}{10104, 8080}
+			},
+			want: "~ portDefinitions[1].port: 10105 => 8080",
+		},
+		{
+			name: "changed fields",
+			edit: func(app map[string]interface{}) {
+				app["instances"] = 3
+				app["healthChecks"].([]interface{})[0].(map[string]interface{})["timeoutSeconds"] = 5
+				delete(app, "env")
+				app["unreachableStrategy"] = map[string]interface{}{"inactiveAfterSeconds": 300}
+			},
+			want: "- env: {\"MODE\":\"production\"}\n" +
+				"~ healthChecks[0].timeoutSeconds: 20 => 5\n" +
+				"~ instances: 2 => 3\n" +
+				"~ unreachableStrategy.inactiveAfterSeconds: 0 => 300",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			definition, live := testApp(t, c.edit)
+			desired, err := Normalize(definition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, err := normalizeLive(live, definition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := diff.Compare(current, desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := make([]string, len(changes))
+			for i, change := range changes {
+				lines[i] = change.String()
+			}
+			if got := strings.Join(lines, "\n"); got != c.want {
+				t.Errorf("got changes\n%s\nwant\n%s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package mock provides a Deployer which deploys nothing, behaving as
+// scripted by a scenario instead, so that deployments can be reproduced.
+package mock
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// phase names, as used by the fail, hang, and panic attributes
+const (
+	phaseDeploy = "deploy"
+	phaseHealth = "health"
+)
+
+// defaultBehavior is how deployments behave if a scenario does not say
+// otherwise.
+var defaultBehavior = behavior{
+	deployTime:          timeRange{50 * time.Millisecond, 250 * time.Millisecond},
+	healthTime:          timeRange{200 * time.Millisecond, 700 * time.Millisecond},
+	deployFailureChance: 0.01,
+	healthFailureChance: 0.01,
+}
+
+// behavior is how a single deployment behaves, resolved from a scenario.
+type behavior struct {
+	deployTime          timeRange
+	healthTime          timeRange
+	deployFailureChance float64
+	healthFailureChance float64
+	fail, hang, panic   string
+}
+
+// apply overrides the fields of b which are set by block, which must have
+// been validated.
+func (b *behavior) apply(block *Behavior) {
+	if block.DeployTime != "" {
+		b.deployTime, _ = parseTimeRange(block.DeployTime)
+	}
+	if block.HealthTime != "" {
+		b.healthTime, _ = parseTimeRange(block.HealthTime)
+	}
+	if block.DeployFailureChance != nil {
+		b.deployFailureChance = *block.DeployFailureChance
+	}
+	if block.HealthFailureChance != nil {
+		b.healthFailureChance = *block.HealthFailureChance
+	}
+	if block.Fail != "" {
+		b.fail = block.Fail
+	}
+	if block.Hang != "" {
+		b.hang = block.Hang
+	}
+	if block.Panic != "" {
+		b.panic = block.Panic
+	}
+}
+
+// Deployer is a deploy.Deployer which sleeps instead of deploying, and fails,
+// hangs, or panics as its scenario says.
+// Random durations and failures are drawn from a separate source for each
+// deployment, seeded from the seed of the Deployer and the deployment, so the
+// outcome of each deployment does not depend on the order they run in.
+type Deployer struct {
+	seed        int64
+	defaults    behavior
+	behaviors   map[model.DeploymentRef]behavior
+	mutex       sync.Mutex
+	rngs        map[model.DeploymentRef]*rand.Rand
+	released    chan struct{}
+	releaseOnce sync.Once
+	hangTimeout time.Duration
+}
+
+var (
+	_ deploy.Deployer  = &Deployer{}
+	_ deploy.Restarter = &Deployer{}
+	_ deploy.Destroyer = &Deployer{}
+)
+
+// New creates a Deployer for the deployments of root which behaves as given by
+// scenario, or as a Deployer with an empty scenario if it is nil.
+// If seed is zero, the seed of scenario is used, and if that is also zero,
+// the current time is used.
+// The scenario must have been decoded without errors.
+func New(root *model.Root, scenario *Scenario, seed int64) *Deployer {
+	if scenario == nil {
+		scenario = &Scenario{}
+	}
+	if seed == 0 {
+		seed = scenario.Seed
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	d := &Deployer{
+		seed:      seed,
+		defaults:  defaultBehavior,
+		behaviors: make(map[model.DeploymentRef]behavior, len(root.Deployments)),
+		rngs:      make(map[model.DeploymentRef]*rand.Rand),
+		released:  make(chan struct{}),
+	}
+	for i := range scenario.Defaults {
+		d.defaults.apply(&scenario.Defaults[i])
+	}
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		b := d.defaults
+		for j := range scenario.Labels {
+			for _, label := range deployment.Labels {
+				if label == scenario.Labels[j].Target {
+					b.apply(&scenario.Labels[j])
+					break
+				}
+			}
+		}
+		for j := range scenario.Deployments {
+			if ref, err := model.ParseDeploymentRef(scenario.Deployments[j].Target); err == nil &&
+				ref == deployment.Ref() {
+				b.apply(&scenario.Deployments[j])
+			}
+		}
+		d.behaviors[deployment.Ref()] = b
+	}
+	return d
+}
+
+// Seed returns the seed of d, with which its behavior can be reproduced.
+func (d *Deployer) Seed() int64 {
+	return d.seed
+}
+
+// Release makes every phase which is hanging, or which later hangs, fail
+// instead.
+func (d *Deployer) Release() {
+	d.releaseOnce.Do(func() {
+		close(d.released)
+	})
+}
+
+// SetHangTimeout makes each phase which hangs fail after timeout, unless it
+// is released first.
+// A timeout of zero, the default, lets phases hang until released.
+// SetHangTimeout must be called before d is used.
+func (d *Deployer) SetHangTimeout(timeout time.Duration) {
+	d.hangTimeout = timeout
+}
+
+// Deploy simulates the deploy phase of ref.
+func (d *Deployer) Deploy(ref model.DeploymentRef) error {
+	b := d.behavior(ref)
+	return d.runPhase(ref, phaseDeploy, &b, b.deployTime, b.deployFailureChance)
+}
+
+// Restart simulates restarting ref, which behaves like its deploy phase.
+func (d *Deployer) Restart(ref model.DeploymentRef) error {
+	return d.Deploy(ref)
+}
+
+// Destroy simulates destroying ref, which behaves like its deploy phase.
+func (d *Deployer) Destroy(ref model.DeploymentRef) error {
+	return d.Deploy(ref)
+}
+
+// WaitUntilHealthy simulates the health phase of ref.
+func (d *Deployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	b := d.behavior(ref)
+	return d.runPhase(ref, phaseHealth, &b, b.healthTime, b.healthFailureChance)
+}
+
+// behavior returns how ref behaves.
+func (d *Deployer) behavior(ref model.DeploymentRef) behavior {
+	if b, ok := d.behaviors[ref]; ok {
+		return b
+	}
+	return d.defaults
+}
+
+// runPhase sleeps for a duration drawn from times, then fails with the given
+// chance, unless b says the phase should fail, hang, or panic.
+func (d *Deployer) runPhase(ref model.DeploymentRef, phase string, b *behavior, times timeRange,
+	failureChance float64) error {
+	d.mutex.Lock()
+	rng, ok := d.rngs[ref]
+	if !ok {
+		hash := fnv.New64a()
+		fmt.Fprintf(hash, "%s.%s", ref.Type, ref.Name)
+		rng = rand.New(rand.NewSource(d.seed ^ int64(hash.Sum64())))
+		d.rngs[ref] = rng
+	}
+	wait := times.min
+	if times.max > times.min {
+		wait += time.Duration(rng.Int63n(int64(times.max - times.min)))
+	}
+	failed := rng.Float64() < failureChance
+	d.mutex.Unlock()
+	if b.hang == phase {
+		var timeout <-chan time.Time
+		if d.hangTimeout > 0 {
+			timer := time.NewTimer(d.hangTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-d.released:
+			return fmt.Errorf("mock %s phase hung until released", phase)
+		case <-timeout:
+			return fmt.Errorf("mock %s phase hung for %s", phase, d.hangTimeout)
+		}
+	}
+	time.Sleep(wait)
+	if b.panic == phase {
+		panic(fmt.Sprintf("mock %s phase panic for %s.%s", phase, ref.Type, ref.Name))
+	}
+	if b.fail == phase {
+		return fmt.Errorf("mock %s phase failure", phase)
+	} else if failed {
+		return fmt.Errorf("mock %s phase random failure", phase)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package mock
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// testRoot returns the deployments a, b, c, and d, where c has the label
+// "slow", and the graph in which a waits for b and c to become healthy and b
+// and c depend on d without waiting.
+func testRoot(t *testing.T) (*model.Root, *model.Graph) {
+	t.Helper()
+	d := model.DependencySpec{Type: "marathon_app", Name: "d"}
+	root := &model.Root{
+		Deployments: []model.Deployment{
+			{Type: "marathon_app", Name: "a", Dependencies: []model.DependencySpec{
+				{Type: "marathon_app", Name: "b", WaitForHealthy: true},
+				{Type: "marathon_app", Name: "c", WaitForHealthy: true},
+			}},
+			{Type: "marathon_app", Name: "b", Dependencies: []model.DependencySpec{d}},
+			{Type: "marathon_app", Name: "c", Labels: []string{"slow"}, Dependencies: []model.DependencySpec{d}},
+			{Type: "marathon_app", Name: "d"},
+		},
+	}
+	var graph model.Graph
+	if err := graph.Build(root.Deployments...); err != nil {
+		t.Fatal(err)
+	}
+	return root, &graph
+}
+
+// testScenario returns a scenario in which phases take a millisecond and
+// never fail at random, with the given deployment blocks.
+func testScenario(deployments ...Behavior) *Scenario {
+	zero := 0.0
+	return &Scenario{
+		Seed: 1,
+		Defaults: []Behavior{{
+			DeployTime:          "1ms",
+			HealthTime:          "1ms",
+			DeployFailureChance: &zero,
+			HealthFailureChance: &zero,
+		}},
+		Deployments: deployments,
+	}
+}
+
+// outcomes runs graphDeployer with run, which is its Deploy or Destroy
+// method, and returns the outcome of each deployment by name, which is "ok"
+// or the error of its failure, along with the order the deployments
+// succeeded in.
+func outcomes(t *testing.T, run func(chan<- deploy.Event) error) (map[string]string, []string) {
+	t.Helper()
+	events := make(chan deploy.Event, 100)
+	result := make(map[string]string)
+	var order []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			switch event.Type {
+			case deploy.EventDeploymentSuccess:
+				result[event.Deployment.Ref().Name] = "ok"
+				order = append(order, event.Deployment.Ref().Name)
+			case deploy.EventDeploymentFailure:
+				result[event.Deployment.Ref().Name] = event.Err.Error()
+			}
+		}
+	}()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		run(events)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deployment did not finish")
+	}
+	<-done
+	return result, order
+}
+
+func TestGraphDeployerFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		behaviors   []Behavior
+		hangTimeout time.Duration
+		release     bool
+		outcomes    map[string]string
+		panicked    string
+		successful  int32
+		failed      int32
+	}{
+		{
+			name:       "success",
+			outcomes:   map[string]string{"a": "ok", "b": "ok", "c": "ok", "d": "ok"},
+			successful: 4,
+		},
+		{
+			name:      "deploy failure",
+			behaviors: []Behavior{{Target: "marathon_app.d", Fail: phaseDeploy}},
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.b failed to deploy",
+				"b": "dependency marathon_app.d failed to deploy",
+				"c": "dependency marathon_app.d failed to deploy",
+				"d": "failed to deploy to framework: mock deploy phase failure",
+			},
+			failed: 4,
+		},
+		{
+			name:      "health failure not waited on",
+			behaviors: []Behavior{{Target: "marathon_app.d", Fail: phaseHealth}},
+			outcomes: map[string]string{
+				"a": "ok",
+				"b": "ok",
+				"c": "ok",
+				"d": "failed to wait until framework considered deployment healthy: mock health phase failure",
+			},
+			successful: 3,
+			failed:     1,
+		},
+		{
+			name:      "health failure waited on",
+			behaviors: []Behavior{{Target: "marathon_app.c", Fail: phaseHealth}},
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.c failed to become healthy",
+				"b": "ok",
+				"c": "failed to wait until framework considered deployment healthy: mock health phase failure",
+				"d": "ok",
+			},
+			successful: 2,
+			failed:     2,
+		},
+		{
+			name:        "hang timeout",
+			behaviors:   []Behavior{{Target: "marathon_app.c", Hang: phaseHealth}},
+			hangTimeout: 10 * time.Millisecond,
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.c failed to become healthy",
+				"b": "ok",
+				"c": "failed to wait until framework considered deployment healthy: mock health phase hung for 10ms",
+				"d": "ok",
+			},
+			successful: 2,
+			failed:     2,
+		},
+		{
+			name:      "hang released",
+			behaviors: []Behavior{{Target: "marathon_app.d", Hang: phaseDeploy}},
+			release:   true,
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.b failed to deploy",
+				"b": "dependency marathon_app.d failed to deploy",
+				"c": "dependency marathon_app.d failed to deploy",
+				"d": "failed to deploy to framework: mock deploy phase hung until released",
+			},
+			failed: 4,
+		},
+		{
+			name:      "deploy panic",
+			behaviors: []Behavior{{Target: "marathon_app.d", Panic: phaseDeploy}},
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.b failed to deploy",
+				"b": "dependency marathon_app.d failed to deploy: panic: mock deploy phase panic for marathon_app.d",
+				"c": "dependency marathon_app.d failed to deploy: panic: mock deploy phase panic for marathon_app.d",
+				"d": "panic: mock deploy phase panic for marathon_app.d",
+			},
+			panicked: "d",
+			failed:   4,
+		},
+		{
+			name:      "health panic waited on",
+			behaviors: []Behavior{{Target: "marathon_app.c", Panic: phaseHealth}},
+			outcomes: map[string]string{
+				"a": "dependency marathon_app.c failed to become healthy: panic: mock health phase panic for marathon_app.c",
+				"b": "ok",
+				"c": "panic: mock health phase panic for marathon_app.c",
+				"d": "ok",
+			},
+			panicked:   "c",
+			successful: 2,
+			failed:     2,
+		},
+		{
+			name:      "health panic not waited on",
+			behaviors: []Behavior{{Target: "marathon_app.d", Panic: phaseHealth}},
+			outcomes: map[string]string{
+				"a": "ok",
+				"b": "ok",
+				"c": "ok",
+				"d": "panic: mock health phase panic for marathon_app.d",
+			},
+			panicked:   "d",
+			successful: 3,
+			failed:     1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, graph := testRoot(t)
+			deployer := New(root, testScenario(test.behaviors...), 0)
+			deployer.SetHangTimeout(test.hangTimeout)
+			if test.release {
+				time.AfterFunc(10*time.Millisecond, deployer.Release)
+			}
+			graphDeployer, err := deploy.NewGraphDeployer(graph, deployer, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var panicked []string
+			actual, _ := outcomes(t, func(events chan<- deploy.Event) error {
+				// record the deployments which panicked before passing on
+				// each event
+				forwarded := make(chan deploy.Event)
+				go func() {
+					defer close(events)
+					for event := range forwarded {
+						if event.Type == deploy.EventDeploymentFailure &&
+							event.Deployment.Status() == deploy.StatusPanic {
+							panicked = append(panicked, event.Deployment.Ref().Name)
+						}
+						events <- event
+					}
+				}()
+				return graphDeployer.Deploy(forwarded)
+			})
+			for name, expected := range test.outcomes {
+				if actual[name] != expected && !strings.HasPrefix(actual[name], expected+": ") {
+					t.Errorf("%s: got %q, want %q", name, actual[name], expected)
+				}
+			}
+			if strings.Join(panicked, " ") != test.panicked {
+				t.Errorf("got panicked deployments %q, want %q", panicked, test.panicked)
+			}
+			stats := graphDeployer.Stats()
+			if stats.SuccessfulDeployments != test.successful || stats.FailedDeployments != test.failed {
+				t.Errorf("got %d successful and %d failed, want %d and %d", stats.SuccessfulDeployments,
+					stats.FailedDeployments, test.successful, test.failed)
+			}
+		})
+	}
+}
+
+func TestDeployerSeed(t *testing.T) {
+	root, _ := testRoot(t)
+	chance := 0.5
+	scenario := &Scenario{Defaults: []Behavior{{
+		DeployTime:          "0s",
+		HealthTime:          "0s",
+		DeployFailureChance: &chance,
+	}}}
+	results := func(seed int64) string {
+		deployer := New(root, scenario, seed)
+		var result strings.Builder
+		for i := 0; i < 4; i++ {
+			for j := range root.Deployments {
+				if deployer.Deploy(root.Deployments[j].Ref()) != nil {
+					result.WriteByte('x')
+				} else {
+					result.WriteByte('.')
+				}
+			}
+		}
+		return result.String()
+	}
+	if first, second := results(7), results(7); first != second {
+		t.Errorf("same seed gave %s and then %s", first, second)
+	}
+}
+
+func TestGraphDeployerExisting(t *testing.T) {
+	root, graph := testRoot(t)
+	deployer := New(root, testScenario(Behavior{Target: "marathon_app.d", Fail: phaseDeploy}), 0)
+	graphDeployer, err := deploy.NewGraphDeployer(graph, deployer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// d would fail if it were deployed
+	graphDeployer.SetExisting(model.DeploymentRef{Type: "marathon_app", Name: "d"})
+	actual, _ := outcomes(t, graphDeployer.Deploy)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if actual[name] != "ok" {
+			t.Errorf("%s: got %q, want ok", name, actual[name])
+		}
+	}
+	stats := graphDeployer.Stats()
+	if stats.SuccessfulDeployments != 3 || stats.ExistingDeployments != 1 || stats.FailedDeployments != 0 {
+		t.Errorf("got %d successful, %d existing, and %d failed, want 3, 1, and 0", stats.SuccessfulDeployments,
+			stats.ExistingDeployments, stats.FailedDeployments)
+	}
+}
+
+func TestGraphDeployerDeployedEvent(t *testing.T) {
+	root, graph := testRoot(t)
+	deployer := New(root, testScenario(Behavior{Target: "marathon_app.c", Fail: phaseHealth}), 0)
+	graphDeployer, err := deploy.NewGraphDeployer(graph, deployer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphDeployer.SetExisting(model.DeploymentRef{Type: "marathon_app", Name: "d"})
+	events := make(chan deploy.Event, 100)
+	go graphDeployer.Deploy(events)
+	// c is deployed even though it never becomes healthy, and a is not
+	// deployed because it waits for c
+	deployed := make(map[string]bool)
+	for event := range events {
+		name := event.Deployment.Ref().Name
+		switch event.Type {
+		case deploy.EventDeployed:
+			deployed[name] = true
+		case deploy.EventDeploymentSuccess:
+			if !deployed[name] {
+				t.Errorf("%s: got EventDeploymentSuccess before EventDeployed", name)
+			}
+		}
+	}
+	for name, want := range map[string]bool{"a": false, "b": true, "c": true, "d": true} {
+		if deployed[name] != want {
+			t.Errorf("%s: got deployed %t, want %t", name, deployed[name], want)
+		}
+	}
+}
+
+func TestGraphDeployerRestartWaitsForHealthy(t *testing.T) {
+	refs := []model.DeploymentRef{
+		{Type: "marathon_app", Name: "restarted"},
+		{Type: "marathon_app", Name: "existing"},
+		{Type: "marathon_app", Name: "target"},
+	}
+	root := &model.Root{}
+	for _, ref := range refs {
+		root.Deployments = append(root.Deployments, model.Deployment{Type: ref.Type, Name: ref.Name})
+	}
+	// the restart edge is implied by the path through the existing
+	// deployment, but only if neither waits for healthy
+	var graph model.Graph
+	err := graph.BuildFromEdges(refs, []model.Edge{
+		{From: refs[0], To: refs[1]},
+		{From: refs[0], To: refs[2], RestartOnChange: true},
+		{From: refs[1], To: refs[2]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployer := New(root, testScenario(Behavior{Target: "marathon_app.target", HealthTime: "50ms"}), 0)
+	graphDeployer, err := deploy.NewGraphDeployer(&graph, deployer, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphDeployer.SetExisting(refs[1])
+	graphDeployer.SetRestart(refs[0])
+	_, order := outcomes(t, graphDeployer.Deploy)
+	if actual := strings.Join(order, " "); actual != "existing target restarted" {
+		t.Errorf("got success order %q, want \"existing target restarted\"", actual)
+	}
+}
+
+func TestGraphDeployerDestroy(t *testing.T) {
+	tests := []struct {
+		name       string
+		behaviors  []Behavior
+		outcomes   map[string]string
+		successful int32
+		failed     int32
+	}{
+		{
+			name:       "success",
+			outcomes:   map[string]string{"a": "ok", "b": "ok", "c": "ok", "d": "ok"},
+			successful: 4,
+		},
+		{
+			name:      "dependent failure",
+			behaviors: []Behavior{{Target: "marathon_app.b", Fail: phaseDeploy}},
+			outcomes: map[string]string{
+				"a": "ok",
+				"b": "failed to destroy in framework: mock deploy phase failure",
+				"c": "ok",
+				"d": "dependent marathon_app.b failed to be destroyed",
+			},
+			successful: 2,
+			failed:     2,
+		},
+		{
+			name: "health failure ignored",
+			// destroyed deployments have no health phase
+			behaviors:  []Behavior{{Target: "marathon_app.a", Fail: phaseHealth}},
+			outcomes:   map[string]string{"a": "ok", "b": "ok", "c": "ok", "d": "ok"},
+			successful: 4,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, graph := testRoot(t)
+			deployer := New(root, testScenario(test.behaviors...), 0)
+			graphDeployer, err := deploy.NewGraphDeployer(graph, deployer, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, order := outcomes(t, graphDeployer.Destroy)
+			for name, expected := range test.outcomes {
+				if actual[name] != expected && !strings.HasPrefix(actual[name], expected+": ") {
+					t.Errorf("%s: got %q, want %q", name, actual[name], expected)
+				}
+			}
+			// every deployment is destroyed after all of its dependents
+			position := make(map[string]int, len(order))
+			for i, name := range order {
+				position[name] = i
+			}
+			for _, edge := range graph.Edges() {
+				from, fromOK := position[edge.From.Name]
+				to, toOK := position[edge.To.Name]
+				if toOK && (!fromOK || to < from) {
+					t.Errorf("%s was destroyed before its dependent %s", edge.To.Name, edge.From.Name)
+				}
+			}
+			stats := graphDeployer.Stats()
+			if stats.SuccessfulDeployments != test.successful || stats.FailedDeployments != test.failed {
+				t.Errorf("got %d successful and %d failed, want %d and %d", stats.SuccessfulDeployments,
+					stats.FailedDeployments, test.successful, test.failed)
+			}
+		})
+	}
+}
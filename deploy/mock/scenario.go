@@ -0,0 +1,249 @@
+package mock
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kbolino/mesosdef/model"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// scenarioSchema is the schema of the top level of a scenario file.
+var scenarioSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "seed"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "defaults"},
+		{Type: "label", LabelNames: []string{"label"}},
+		{Type: "deployment", LabelNames: []string{"deployment"}},
+	},
+}
+
+// Scenario describes how a Deployer behaves.
+// A deployment behaves as given by the defaults block, then by the label
+// blocks for each of its labels in the order they are declared, and then by
+// its own deployment blocks, with later blocks overriding earlier ones.
+type Scenario struct {
+	// Seed is used by New if it is not given a seed, unless it is zero.
+	Seed        int64
+	Defaults    []Behavior
+	Labels      []Behavior
+	Deployments []Behavior
+}
+
+// Behavior is a block of a scenario which sets how some deployments behave.
+// Empty fields are left as set by earlier blocks.
+type Behavior struct {
+	// DeployTime and HealthTime are durations such as "2s", or ranges of
+	// them such as "50ms-250ms" to choose from at random.
+	DeployTime string `hcl:"deploy_time,optional"`
+	HealthTime string `hcl:"health_time,optional"`
+	// DeployFailureChance and HealthFailureChance are the probabilities of
+	// each phase failing.
+	DeployFailureChance *float64 `hcl:"deploy_failure_chance,optional"`
+	HealthFailureChance *float64 `hcl:"health_failure_chance,optional"`
+	// Fail, Hang, and Panic name the phase, "deploy" or "health", which
+	// always fails, never finishes, or panics, respectively.
+	Fail  string `hcl:"fail,optional"`
+	Hang  string `hcl:"hang,optional"`
+	Panic string `hcl:"panic,optional"`
+	// Target is the label of the block: the label for a label block, or the
+	// type.name of the deployment for a deployment block.
+	Target      string
+	DeclRange   hcl.Range
+	TargetRange hcl.Range
+}
+
+// DecodeScenarioFile parses the named file with parser and decodes it into
+// scenario.
+// Files with the extension ".json" are parsed as HCL JSON, all other files
+// are parsed as native HCL syntax.
+func DecodeScenarioFile(parser *hclparse.Parser, filename string, scenario *Scenario) hcl.Diagnostics {
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if filepath.Ext(filename) == ".json" {
+		file, diags = parser.ParseJSONFile(filename)
+	} else {
+		file, diags = parser.ParseHCLFile(filename)
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	content, contentDiags := file.Body.Content(scenarioSchema)
+	diags = append(diags, contentDiags...)
+	if attr, ok := content.Attributes["seed"]; ok {
+		diags = append(diags, gohcl.DecodeExpression(attr.Expr, nil, &scenario.Seed)...)
+	}
+	for _, block := range content.Blocks {
+		var behavior Behavior
+		blockDiags := gohcl.DecodeBody(block.Body, nil, &behavior)
+		diags = append(diags, blockDiags...)
+		if blockDiags.HasErrors() {
+			continue
+		}
+		behavior.DeclRange = block.DefRange
+		behavior.TargetRange = block.DefRange
+		if len(block.Labels) != 0 {
+			behavior.Target = block.Labels[0]
+			behavior.TargetRange = block.LabelRanges[0]
+		}
+		diags = append(diags, validateBehavior(&behavior, block.Body)...)
+		switch block.Type {
+		case "defaults":
+			scenario.Defaults = append(scenario.Defaults, behavior)
+		case "label":
+			scenario.Labels = append(scenario.Labels, behavior)
+		case "deployment":
+			if _, err := model.ParseDeploymentRef(behavior.Target); err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid deployment reference",
+					Detail:   fmt.Sprintf("The label of a deployment block must be of the form type.name: %s.", err),
+					Subject:  behavior.TargetRange.Ptr(),
+				})
+			}
+			scenario.Deployments = append(scenario.Deployments, behavior)
+		}
+	}
+	return diags
+}
+
+// Validate checks that every deployment block of s names a deployment in
+// root, and warns about label blocks matching no deployment.
+func (s *Scenario) Validate(root *model.Root) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	refs := make(map[model.DeploymentRef]bool, len(root.Deployments))
+	labels := make(map[string]bool)
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		refs[deployment.Ref()] = true
+		for _, label := range deployment.Labels {
+			labels[label] = true
+		}
+	}
+	for i := range s.Deployments {
+		behavior := &s.Deployments[i]
+		ref, err := model.ParseDeploymentRef(behavior.Target)
+		if err == nil && !refs[ref] {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Reference to undeclared deployment",
+				Detail:   fmt.Sprintf("No deployment %s is declared.", behavior.Target),
+				Subject:  behavior.TargetRange.Ptr(),
+			})
+		}
+	}
+	for i := range s.Labels {
+		behavior := &s.Labels[i]
+		if !labels[behavior.Target] {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Label matches no deployments",
+				Detail:   fmt.Sprintf("No deployment has the label \"%s\", so this block has no effect.", behavior.Target),
+				Subject:  behavior.TargetRange.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// validateBehavior checks the values of the attributes of behavior, which
+// was decoded from body.
+func validateBehavior(behavior *Behavior, body hcl.Body) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, attr := range []struct{ name, value string }{
+		{"deploy_time", behavior.DeployTime},
+		{"health_time", behavior.HealthTime},
+	} {
+		name, value := attr.name, attr.value
+		if value == "" {
+			continue
+		}
+		if _, err := parseTimeRange(value); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid duration",
+				Detail: fmt.Sprintf("The value of %s must be a duration such as \"2s\" or a range such as "+
+					"\"50ms-250ms\": %s.", name, err),
+				Subject: attributeRange(body, name, behavior.DeclRange).Ptr(),
+			})
+		}
+	}
+	for _, attr := range []struct {
+		name  string
+		value *float64
+	}{
+		{"deploy_failure_chance", behavior.DeployFailureChance},
+		{"health_failure_chance", behavior.HealthFailureChance},
+	} {
+		name, value := attr.name, attr.value
+		if value != nil && (*value < 0 || *value > 1) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid probability",
+				Detail:   fmt.Sprintf("The value of %s must be between 0 and 1.", name),
+				Subject:  attributeRange(body, name, behavior.DeclRange).Ptr(),
+			})
+		}
+	}
+	for _, attr := range []struct{ name, value string }{
+		{"fail", behavior.Fail},
+		{"hang", behavior.Hang},
+		{"panic", behavior.Panic},
+	} {
+		name, value := attr.name, attr.value
+		if value != "" && value != phaseDeploy && value != phaseHealth {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid phase",
+				Detail: fmt.Sprintf("The value of %s must be \"%s\" or \"%s\", not \"%s\".", name, phaseDeploy,
+					phaseHealth, value),
+				Subject: attributeRange(body, name, behavior.DeclRange).Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// timeRange is a range of durations to choose from uniformly.
+type timeRange struct {
+	min, max time.Duration
+}
+
+// parseTimeRange parses a duration such as "2s" or a range of durations such
+// as "50ms-250ms".
+func parseTimeRange(s string) (timeRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	min, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return timeRange{}, err
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = time.ParseDuration(strings.TrimSpace(parts[1])); err != nil {
+			return timeRange{}, err
+		}
+	}
+	if min < 0 || max < min {
+		return timeRange{}, fmt.Errorf("\"%s\" is not a range of non-negative durations", s)
+	}
+	return timeRange{min, max}, nil
+}
+
+// attributeRange returns the range of the value of the named attribute in
+// body, or fallback if body has no such attribute.
+func attributeRange(body hcl.Body, name string, fallback hcl.Range) hcl.Range {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: name}},
+	})
+	if attr, ok := content.Attributes[name]; ok {
+		return attr.Expr.Range()
+	}
+	return fallback
+}
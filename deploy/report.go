@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Report records the outcome and timing of every deployment run by a
+// GraphDeployer, so that later runs can be estimated from it.
+type Report struct {
+	Deployments []ReportEntry `json:"deployments"`
+}
+
+// ReportEntry records the outcome and timing of a single deployment.
+// The durations are omitted if the deployment never reached the end of the
+// corresponding phase.
+type ReportEntry struct {
+	Type          string   `json:"type"`
+	Name          string   `json:"name"`
+	Status        string   `json:"status"`
+	DeploySeconds *float64 `json:"deploy_seconds,omitempty"`
+	HealthSeconds *float64 `json:"health_seconds,omitempty"`
+}
+
+// Ref returns the DeploymentRef for e.
+func (e *ReportEntry) Ref() model.DeploymentRef {
+	return model.DeploymentRef{
+		Type: e.Type,
+		Name: e.Name,
+	}
+}
+
+// Report returns a report on the deployment, which is only meaningful after
+// Deploy has been called.
+func (d *GraphDeployer) Report() *Report {
+	report := &Report{
+		Deployments: make([]ReportEntry, len(d.deployments)),
+	}
+	for i := range d.deployments {
+		deployment := &d.deployments[i]
+		ref := deployment.Ref()
+		entry := &report.Deployments[i]
+		entry.Type = ref.Type
+		entry.Name = ref.Name
+		entry.Status = deployment.Status().String()
+		start, deployed, healthy := deployment.Times()
+		if !deployed.IsZero() {
+			seconds := deployed.Sub(start).Seconds()
+			entry.DeploySeconds = &seconds
+		}
+		if !healthy.IsZero() {
+			seconds := healthy.Sub(deployed).Seconds()
+			entry.HealthSeconds = &seconds
+		}
+	}
+	return report
+}
+
+// ReadReport reads a report written by WriteReport.
+func ReadReport(filename string) (*Report, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing report %s: %w", filename, err)
+	}
+	return &report, nil
+}
+
+// WriteReport writes report to a file as JSON.
+func WriteReport(filename string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	if err := ioutil.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// Estimates averages the durations of each deployment over reports.
+// A deployment is only included if at least one report has a duration for
+// it, and a phase with no durations is estimated as zero.
+func Estimates(reports ...*Report) map[model.DeploymentRef]model.Estimate {
+	type totals struct {
+		deploy, health           float64
+		deployCount, healthCount int
+	}
+	byRef := make(map[model.DeploymentRef]*totals)
+	for _, report := range reports {
+		for i := range report.Deployments {
+			entry := &report.Deployments[i]
+			if entry.DeploySeconds == nil {
+				continue
+			}
+			t, ok := byRef[entry.Ref()]
+			if !ok {
+				t = &totals{}
+				byRef[entry.Ref()] = t
+			}
+			t.deploy += *entry.DeploySeconds
+			t.deployCount++
+			if entry.HealthSeconds != nil {
+				t.health += *entry.HealthSeconds
+				t.healthCount++
+			}
+		}
+	}
+	estimates := make(map[model.DeploymentRef]model.Estimate, len(byRef))
+	for ref, t := range byRef {
+		var estimate model.Estimate
+		estimate.Deploy = secondsToDuration(t.deploy / float64(t.deployCount))
+		if t.healthCount != 0 {
+			estimate.Health = secondsToDuration(t.health / float64(t.healthCount))
+		}
+		estimates[ref] = estimate
+	}
+	return estimates
+}
+
+// secondsToDuration converts a number of seconds to a duration.
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,204 @@
+package deploy
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Distribution is a uniform distribution of durations from Min to Max.
+type Distribution struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// sample draws a duration from d using rng.
+func (d Distribution) sample(rng *rand.Rand) time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rng.Int63n(int64(d.Max-d.Min)))
+}
+
+// SimProfile describes how a deployment behaves in a simulation.
+type SimProfile struct {
+	Deploy Distribution
+	Health Distribution
+	// DeployFailure is the probability that the deploy phase fails at its end.
+	DeployFailure float64
+	// HealthFailure is the probability that the health phase fails at its
+	// end.
+	HealthFailure float64
+}
+
+// Simulation runs the scheduling logic of a GraphDeployer on a virtual clock,
+// so that a deployment can be estimated without waiting for it.
+type Simulation struct {
+	Graph *model.Graph
+	// Profiles holds the behavior of individual deployments, and
+	// DefaultProfile the behavior of those not in Profiles.
+	Profiles       map[model.DeploymentRef]SimProfile
+	DefaultProfile SimProfile
+	// steps is the deploy order and reduced dependencies of Graph, which are
+	// resolved by the first call to Run
+	steps []simStep
+}
+
+// simStep is a deployment to simulate, with the indices of its dependencies
+// in deploy order.
+type simStep struct {
+	profile      SimProfile
+	dependencies []int
+	waits        []bool
+}
+
+// SimResult is the outcome of a single simulated deployment.
+type SimResult struct {
+	Makespan              time.Duration
+	SuccessfulDeployments int
+	FailedDeployments     int
+	// Busy is the total time workers spent in deploy and health phases.
+	Busy time.Duration
+	// Blocked is the total time workers spent holding a deployment while
+	// waiting on its dependencies.
+	Blocked time.Duration
+}
+
+// Utilization returns the fraction of the time of maxDeploy workers spent
+// in deploy and health phases.
+func (r *SimResult) Utilization(maxDeploy int) float64 {
+	if r.Makespan == 0 || maxDeploy == 0 {
+		return 0
+	}
+	return float64(r.Busy) / float64(r.Makespan) / float64(maxDeploy)
+}
+
+// simDeployment is the state of a deployment in a simulation.
+// Its times are offsets from the start of the simulation, and its deployed or
+// healthy time is the time the corresponding phase failed if it did.
+type simDeployment struct {
+	deployed, healthy          time.Duration
+	deployFailed, healthFailed bool
+}
+
+// Run simulates a deployment with at most maxDeploy concurrent deployments,
+// drawing durations and failures from rng.
+// As with GraphDeployer, deployments are handed to the first free worker in
+// deploy order, the worker waits on their dependencies one at a time, and a
+// deployment fails without starting if any of its dependencies fails.
+// Returns a non-nil error if and only if there are cycles in the graph.
+func (s *Simulation) Run(maxDeploy int, rng *rand.Rand) (*SimResult, error) {
+	if maxDeploy < 1 {
+		return nil, fmt.Errorf("maxDeploy must be at least 1")
+	}
+	if s.steps == nil {
+		if err := s.resolve(); err != nil {
+			return nil, err
+		}
+	}
+	result := &SimResult{}
+	workerFree := make([]time.Duration, maxDeploy)
+	deployments := make([]simDeployment, len(s.steps))
+	for i := range s.steps {
+		step := &s.steps[i]
+		current := &deployments[i]
+		// the first worker to become free dequeues the deployment
+		worker := 0
+		for j := range workerFree {
+			if workerFree[j] < workerFree[worker] {
+				worker = j
+			}
+		}
+		dequeued := workerFree[worker]
+		// wait on each dependency in turn
+		now := dequeued
+		failed := false
+		for j, k := range step.dependencies {
+			dependency := &deployments[k]
+			now = maxDuration(now, dependency.deployed)
+			if dependency.deployFailed {
+				failed = true
+				break
+			}
+			if step.waits[j] {
+				now = maxDuration(now, dependency.healthy)
+				if dependency.healthFailed {
+					failed = true
+					break
+				}
+			}
+		}
+		result.Blocked += now - dequeued
+		if failed {
+			current.deployed, current.healthy = now, now
+			current.deployFailed, current.healthFailed = true, true
+			result.FailedDeployments++
+			workerFree[worker] = now
+			result.Makespan = maxDuration(result.Makespan, now)
+			continue
+		}
+		// run the deploy and health phases
+		start := now
+		current.deployed = start + step.profile.Deploy.sample(rng)
+		current.healthy = current.deployed
+		if rng.Float64() < step.profile.DeployFailure {
+			current.deployFailed, current.healthFailed = true, true
+		} else {
+			current.healthy += step.profile.Health.sample(rng)
+			current.healthFailed = rng.Float64() < step.profile.HealthFailure
+		}
+		if current.healthFailed {
+			result.FailedDeployments++
+		} else {
+			result.SuccessfulDeployments++
+		}
+		result.Busy += current.healthy - start
+		workerFree[worker] = current.healthy
+		result.Makespan = maxDuration(result.Makespan, current.healthy)
+	}
+	return result, nil
+}
+
+// resolve sets s.steps from the deploy order and transitive reduction of
+// s.Graph, as used by GraphDeployer.
+func (s *Simulation) resolve() error {
+	deployOrder, err := s.Graph.DeployOrder()
+	if err != nil {
+		return fmt.Errorf("resolving deployment order: %w", err)
+	}
+	reduced, err := s.Graph.TransitiveReduction()
+	if err != nil {
+		return fmt.Errorf("reducing dependency graph: %w", err)
+	}
+	index := make(map[model.DeploymentRef]int, len(deployOrder))
+	steps := make([]simStep, len(deployOrder))
+	for i, ref := range deployOrder {
+		index[ref] = i
+		step := &steps[i]
+		profile, ok := s.Profiles[ref]
+		if !ok {
+			profile = s.DefaultProfile
+		}
+		step.profile = profile
+		dependencies, err := reduced.Dependencies(ref)
+		if err != nil {
+			return err
+		}
+		for _, dependency := range dependencies {
+			step.dependencies = append(step.dependencies, index[dependency.DeploymentRef()])
+			step.waits = append(step.waits, dependency.WaitForHealthy)
+		}
+	}
+	s.steps = steps
+	return nil
+}
+
+// maxDuration returns the larger of a and b.
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
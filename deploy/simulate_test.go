@@ -0,0 +1,145 @@
+package deploy
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// simGraph builds a graph of marathon_app deployments a, b, c, and d, where
+// a waits for b and c to become healthy and b and c depend on d without
+// waiting.
+func simGraph(t *testing.T) *model.Graph {
+	t.Helper()
+	d := model.DependencySpec{Type: "marathon_app", Name: "d"}
+	var graph model.Graph
+	err := graph.Build(
+		model.Deployment{Type: "marathon_app", Name: "a", Dependencies: []model.DependencySpec{
+			{Type: "marathon_app", Name: "b", WaitForHealthy: true},
+			{Type: "marathon_app", Name: "c", WaitForHealthy: true},
+		}},
+		model.Deployment{Type: "marathon_app", Name: "b", Dependencies: []model.DependencySpec{d}},
+		model.Deployment{Type: "marathon_app", Name: "c", Dependencies: []model.DependencySpec{d}},
+		model.Deployment{Type: "marathon_app", Name: "d"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &graph
+}
+
+// fixed returns a Distribution of exactly d.
+func fixed(d time.Duration) Distribution {
+	return Distribution{Min: d, Max: d}
+}
+
+func TestSimulationRun(t *testing.T) {
+	slow := model.DeploymentRef{Type: "marathon_app", Name: "c"}
+	tests := []struct {
+		name      string
+		maxDeploy int
+		profile   SimProfile
+		slow      SimProfile
+		result    SimResult
+	}{
+		{
+			name:      "serial",
+			maxDeploy: 1,
+			profile:   SimProfile{Deploy: fixed(time.Second), Health: fixed(time.Second)},
+			slow:      SimProfile{Deploy: fixed(time.Second), Health: fixed(3 * time.Second)},
+			result: SimResult{
+				Makespan:              10 * time.Second,
+				SuccessfulDeployments: 4,
+				Busy:                  10 * time.Second,
+			},
+		},
+		{
+			name:      "parallel",
+			maxDeploy: 4,
+			profile:   SimProfile{Deploy: fixed(time.Second), Health: fixed(time.Second)},
+			slow:      SimProfile{Deploy: fixed(time.Second), Health: fixed(3 * time.Second)},
+			// d deploys at 1s, b and c start then, c is healthy at 5s, and
+			// a waits for it before taking 2s; b and c each wait 1s on d,
+			// and a waits 5s
+			result: SimResult{
+				Makespan:              7 * time.Second,
+				SuccessfulDeployments: 4,
+				Busy:                  10 * time.Second,
+				Blocked:               7 * time.Second,
+			},
+		},
+		{
+			name:      "deploy failure",
+			maxDeploy: 4,
+			profile:   SimProfile{Deploy: fixed(time.Second), Health: fixed(time.Second)},
+			slow:      SimProfile{Deploy: fixed(2 * time.Second), DeployFailure: 1},
+			// c fails at 3s, and a fails without starting when it waits on c
+			result: SimResult{
+				Makespan:              3 * time.Second,
+				SuccessfulDeployments: 2,
+				FailedDeployments:     2,
+				Busy:                  6 * time.Second,
+				Blocked:               1*time.Second + 1*time.Second + 3*time.Second,
+			},
+		},
+		{
+			name:      "health failure",
+			maxDeploy: 4,
+			profile:   SimProfile{Deploy: fixed(time.Second), Health: fixed(time.Second)},
+			slow:      SimProfile{Deploy: fixed(time.Second), Health: fixed(time.Second), HealthFailure: 1},
+			result: SimResult{
+				Makespan:              3 * time.Second,
+				SuccessfulDeployments: 2,
+				FailedDeployments:     2,
+				Busy:                  6 * time.Second,
+				Blocked:               1*time.Second + 1*time.Second + 3*time.Second,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			simulation := &Simulation{
+				Graph:          simGraph(t),
+				Profiles:       map[model.DeploymentRef]SimProfile{slow: test.slow},
+				DefaultProfile: test.profile,
+			}
+			result, err := simulation.Run(test.maxDeploy, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *result != test.result {
+				t.Errorf("got %+v, want %+v", *result, test.result)
+			}
+		})
+	}
+}
+
+func TestSimulationRunDeterministic(t *testing.T) {
+	simulation := &Simulation{
+		Graph: simGraph(t),
+		DefaultProfile: SimProfile{
+			Deploy:        Distribution{Min: time.Second, Max: 10 * time.Second},
+			Health:        Distribution{Min: time.Second, Max: 10 * time.Second},
+			DeployFailure: 0.2,
+			HealthFailure: 0.2,
+		},
+	}
+	for seed := int64(1); seed <= 10; seed++ {
+		first, err := simulation.Run(2, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := simulation.Run(2, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *first != *second {
+			t.Errorf("seed %d: got %+v and then %+v", seed, *first, *second)
+		}
+	}
+	if _, err := simulation.Run(0, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("expected error for maxDeploy 0")
+	}
+}
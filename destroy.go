@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+)
+
+var flagPrune bool
+
+// destroyMain is the entry point for the destroy subcommand.
+func destroyMain(args []string) error {
+	flags := flag.NewFlagSet("destroy", flag.ContinueOnError)
+	addDeployerFlags(flags)
+	addTargetFlags(flags, "destroy", "dependents")
+	addConfigFlags(flags)
+	flags.BoolVar(&flagPrune, "prune", false, "destroy only deployments recorded in the state file which "+
+		"are no longer configured")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s destroy [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Removes deployments from their frameworks in reverse dependency order, so\n"+
+			"that each is removed only after everything depending on it has been removed.\n"+
+			"Deployments are removed by a mock deployer unless -live is given.\n"+
+			"With -prune, deployments recorded in the state file which are no longer\n"+
+			"configured are removed instead.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkCycles(&cfg.graph); err != nil {
+		return err
+	}
+	if flagPrune {
+		return prune(cfg)
+	}
+	selected, err := selectDestroyed(cfg)
+	if err != nil {
+		return err
+	}
+	for _, warning := range selected.warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+	}
+	loader := definition.NewLoader(&cfg.root)
+	deployer, err := newDeployer(cfg, loader, flagLive)
+	if err != nil {
+		return err
+	}
+	recorder, err := newStateRecorder(&cfg.root, &cfg.graph, loader, deployer, flagLive)
+	if err != nil {
+		return err
+	}
+	graphDeployer, err := deploy.NewGraphDeployer(selected.graph, deployer, flagMaxDeploy)
+	if err != nil {
+		return fmt.Errorf("creating graph deployer: %w", err)
+	}
+	if err := runGraphDeployer(graphDeployer, graphDeployer.Destroy, recorder); err != nil {
+		return fmt.Errorf("destroying graph: %w", err)
+	}
+	return nil
+}
+
+// prune destroys the deployments recorded in the state file which are not in
+// the configuration of cfg, identifying them by their recorded app IDs and
+// job names since their definitions are gone.
+func prune(cfg *config) error {
+	if flagState == "" {
+		return fmt.Errorf("-prune requires a state file")
+	}
+	if len(flagTargets) != 0 || len(flagTargetLabels) != 0 || len(flagSelect) != 0 || len(flagExclude) != 0 {
+		return fmt.Errorf("-prune cannot be combined with -target, -target-label, -select, or -exclude")
+	}
+	file, err := openState()
+	if err != nil {
+		return err
+	}
+	entries := unconfigured(cfg, file)
+	if len(entries) == 0 {
+		fmt.Printf("Nothing to prune: every deployment recorded in %s is configured\n", flagState)
+		return nil
+	}
+	pruned := *cfg
+	pruned.root = model.Root{Frameworks: cfg.root.Frameworks}
+	pruned.graph = model.Graph{}
+	refs := make([]model.DeploymentRef, len(entries))
+	included := make(map[model.DeploymentRef]bool, len(entries))
+	for i, entry := range entries {
+		pruned.root.Deployments = append(pruned.root.Deployments, model.Deployment{
+			Type:      entry.Type,
+			Name:      entry.Name,
+			Framework: entry.FrameworkRef().Name,
+		})
+		refs[i] = entry.Ref()
+		included[refs[i]] = true
+	}
+	// destroy dependents first by the dependencies they were deployed with,
+	// ignoring those which are still configured
+	var edges []model.Edge
+	for _, entry := range entries {
+		dependencies, err := entry.DependencyRefs()
+		if err != nil {
+			return fmt.Errorf("state %s is invalid: %w", flagState, err)
+		}
+		for _, dependency := range dependencies {
+			if included[dependency] {
+				edges = append(edges, model.Edge{From: entry.Ref(), To: dependency})
+			}
+		}
+	}
+	if err := pruned.graph.BuildFromEdges(refs, edges); err != nil {
+		return fmt.Errorf("building graph: %w", err)
+	} else if err := checkCycles(&pruned.graph); err != nil {
+		return err
+	}
+	deployer, err := newDeployer(&pruned, file, flagLive)
+	if err != nil {
+		return err
+	}
+	recorder, err := newStateRecorder(&pruned.root, &pruned.graph, file, deployer, flagLive)
+	if err != nil {
+		return err
+	}
+	graphDeployer, err := deploy.NewGraphDeployer(&pruned.graph, deployer, flagMaxDeploy)
+	if err != nil {
+		return fmt.Errorf("creating graph deployer: %w", err)
+	}
+	if err := runGraphDeployer(graphDeployer, graphDeployer.Destroy, recorder); err != nil {
+		return fmt.Errorf("destroying graph: %w", err)
+	}
+	return nil
+}
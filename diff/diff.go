@@ -0,0 +1,154 @@
+// Package diff compares deployment definitions, which are JSON documents,
+// field by field.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Kind indicates how a field differs between two definitions.
+type Kind int
+
+// Kind constants.
+const (
+	Added Kind = iota
+	Removed
+	Changed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	case Changed:
+		return "Changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single difference between two definitions.
+// Path locates the field in the form used by JavaScript, such as
+// container.docker.portMappings[0].hostPort, with keys which are not
+// identifiers quoted as in env["MY-VAR"].
+// Old is unset for an added field and New is unset for a removed one.
+type Change struct {
+	Path string
+	Kind Kind
+	Old  interface{}
+	New  interface{}
+}
+
+// String formats c as a line beginning with +, -, or ~ for an added, removed,
+// or changed field, followed by its path and its values as JSON.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, encode(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, encode(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", c.Path, encode(c.Old), encode(c.New))
+	}
+}
+
+// Compare returns the changes needed to turn the JSON document old into new,
+// sorted by path.
+// Objects are compared field by field and arrays of the same length element
+// by element, while arrays of different lengths are compared as a whole.
+func Compare(old, new []byte) ([]Change, error) {
+	var oldValue, newValue interface{}
+	if err := json.Unmarshal(old, &oldValue); err != nil {
+		return nil, fmt.Errorf("decoding old definition: %w", err)
+	}
+	if err := json.Unmarshal(new, &newValue); err != nil {
+		return nil, fmt.Errorf("decoding new definition: %w", err)
+	}
+	var changes []Change
+	compare("", oldValue, newValue, &changes)
+	return changes, nil
+}
+
+// compare appends the changes from old to new at path to changes.
+func compare(path string, old, new interface{}, changes *[]Change) {
+	switch oldValue := old.(type) {
+	case map[string]interface{}:
+		newValue, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(oldValue)+len(newValue))
+		for key := range oldValue {
+			keys = append(keys, key)
+		}
+		for key := range newValue {
+			if _, ok := oldValue[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := fieldPath(path, key)
+			oldField, inOld := oldValue[key]
+			newField, inNew := newValue[key]
+			if !inOld {
+				*changes = append(*changes, Change{Path: keyPath, Kind: Added, New: newField})
+			} else if !inNew {
+				*changes = append(*changes, Change{Path: keyPath, Kind: Removed, Old: oldField})
+			} else {
+				compare(keyPath, oldField, newField, changes)
+			}
+		}
+		return
+	case []interface{}:
+		newValue, ok := new.([]interface{})
+		if !ok || len(newValue) != len(oldValue) {
+			break
+		}
+		for i := range oldValue {
+			compare(fmt.Sprintf("%s[%d]", path, i), oldValue[i], newValue[i], changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Path: path, Kind: Changed, Old: old, New: new})
+	}
+}
+
+// fieldPath returns the path of the field key of the object at path.
+func fieldPath(path, key string) string {
+	if !isIdentifier(key) {
+		return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+	} else if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isIdentifier returns true if s can be written in a path without quotes.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && c != '$' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns value as compact JSON.
+func encode(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(content)
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "equal",
+			old:  `{"a":1,"b":{"c":[1,2]}}`,
+			new:  `{"b":{"c":[1,2]},"a":1}`,
+		},
+		{
+			name: "added, removed, and changed",
+			old:  `{"a":1,"b":"x","d":true}`,
+			new:  `{"a":2,"c":null,"d":true}`,
+			want: "~ a: 1 => 2\n- b: \"x\"\n+ c: null",
+		},
+		{
+			name: "nested",
+			old:  `{"container":{"docker":{"portMappings":[{"hostPort":0},{"hostPort":80}]}}}`,
+			new:  `{"container":{"docker":{"portMappings":[{"hostPort":0},{"hostPort":8080}]}}}`,
+			want: "~ container.docker.portMappings[1].hostPort: 80 => 8080",
+		},
+		{
+			name: "arrays of different lengths",
+			old:  `{"args":["a","b"]}`,
+			new:  `{"args":["a","b","c"]}`,
+			want: `~ args: ["a","b"] => ["a","b","c"]`,
+		},
+		{
+			name: "quoted keys",
+			old:  `{"env":{"MY-VAR":"1","_OK$1":"1"}}`,
+			new:  `{"env":{"MY-VAR":"2","_OK$1":"2","":"3"}}`,
+			want: "+ env[\"\"]: \"3\"\n~ env[\"MY-VAR\"]: \"1\" => \"2\"\n~ env._OK$1: \"1\" => \"2\"",
+		},
+		{
+			name: "changed type",
+			old:  `{"a":{"b":1}}`,
+			new:  `{"a":[1]}`,
+			want: `~ a: {"b":1} => [1]`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changes, err := Compare([]byte(c.old), []byte(c.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := make([]string, len(changes))
+			for i, change := range changes {
+				lines[i] = change.String()
+			}
+			if got := strings.Join(lines, "\n"); got != c.want {
+				t.Errorf("got\n%s\nwant\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompareInvalid(t *testing.T) {
+	if _, err := Compare([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("invalid old definition: no error")
+	}
+	if _, err := Compare([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Error("invalid new definition: no error")
+	}
+}
@@ -18,9 +18,35 @@ framework "chronos" "default" {
     }
 }
 
+defaults {
+    definition = {
+        env = {
+            DNS_TLD = dns_tld
+        }
+        constraints = [["hostname", "UNIQUE"]]
+    }
+}
+
+defaults {
+    type = "marathon_app"
+
+    filter {
+        key = "labels"
+        value = "monitoring"
+    }
+
+    definition = {
+        labels = {
+            team = "monitoring"
+        }
+    }
+}
+
 deployment "marathon_app" "mesos_dns" {
     deploy = "${deploy_root}/core/mesos-dns.json"
     labels = ["bootstrap"]
+    expected_deploy_time = "10s"
+    expected_health_time = "30s"
 
     dependency_of {
         type = "*"
@@ -63,6 +89,8 @@ deployment "marathon_app" "chronos" {
 deployment "marathon_app" "elasticsearch" {
     deploy = "${deploy_root}/monitoring/kibana.json"
     labels = ["monitoring"]
+    expected_deploy_time = "20s"
+    expected_health_time = "2m"
 }
 
 deployment "marathon_app" "kibana" {
@@ -216,6 +244,11 @@ deployment "marathon_app" "public_proxy" {
 
 deployment "marathon_app" "web_mysql" {
     deploy = "${deploy_root}/web/mysql.json"
+
+    override {
+        instances = 1
+        constraints = [["hostname", "CLUSTER", "db1"]]
+    }
 }
 
 deployment "marathon_app" "web_api_v2" {
@@ -226,6 +259,7 @@ deployment "marathon_app" "web_api_v2" {
         type = "marathon_app"
         name = "web_mysql"
         wait_for_healthy = true
+        restart_on_change = true
     }
 }
 
@@ -245,7 +279,7 @@ deployment "marathon_app" "analytic" {
 
     dependency {
         type = "marathon_app"
-        name = "analyic_service"
+        name = "analytic_service"
         wait_for_healthy = true
     }
 }
@@ -263,4 +297,36 @@ deployment "chronos_job" "minio_cleanup" {
 deployment "chronos_job" "analytic_model_pull" {
     deploy = "${deploy_root}/data/analytic-model-pull.json"
     labels = ["needs_forward_proxy"]
-}
\ No newline at end of file
+}
+deployment "marathon_app" "analytic_worker" {
+    for_each = {
+        us = "us-east-1"
+        eu = "eu-west-1"
+    }
+
+    deploy = "${deploy_root}/data/analytic-worker.json"
+    labels = ["needs_rabbitmq", "needs_minio"]
+
+    template_vars = {
+        region = each.value
+        app_id = format("/data/analytic-worker-%s", lower(each.key))
+    }
+
+    dependency {
+        type = "marathon_app"
+        name = "analytic_service"
+    }
+}
+
+deployment "chronos_job" "analytic_report" {
+    count = 2
+
+    deploy = "${deploy_root}/data/analytic-report-${count.index}.json"
+    labels = ["needs_minio"]
+
+    dependency {
+        type = "marathon_app"
+        name = "analytic_worker"
+        wait_for_healthy = true
+    }
+}
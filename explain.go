@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// explainMain is the entry point for the explain subcommand.
+func explainMain(args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s explain [options] type.name type.name\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Explains why the first deployment depends on the second, by printing\n"+
+			"the shortest dependency path between them and the blocks that produced it.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("explain requires exactly two deployments, got %d", flags.NArg())
+	}
+	from, err := model.ParseDeploymentRef(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	to, err := model.ParseDeploymentRef(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	path, err := cfg.graph.ShortestPath(from, to)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		fmt.Printf("%s.%s does not depend on %s.%s\n", from.Type, from.Name, to.Type, to.Name)
+		if reverse, err := cfg.graph.ShortestPath(to, from); err == nil && len(reverse) != 0 {
+			fmt.Printf("but %s.%s depends on %s.%s; run explain with the arguments swapped for details\n",
+				to.Type, to.Name, from.Type, from.Name)
+		}
+		return nil
+	}
+	fmt.Printf("%s.%s depends on %s.%s through %d hop(s):\n", from.Type, from.Name, to.Type, to.Name, len(path))
+	writeEdges(os.Stdout, "\t", path)
+	return nil
+}
+
+// writeEdges writes each edge followed by the sources that produced it to w,
+// with each line starting with indent.
+func writeEdges(w io.Writer, indent string, edges []model.Edge) {
+	for _, edge := range edges {
+		wait := "immediately after"
+		if edge.WaitForHealthy {
+			wait = "after waiting for"
+		}
+		fmt.Fprintf(w, "%s%s.%s %s %s.%s\n", indent, edge.From.Type, edge.From.Name, wait, edge.To.Type,
+			edge.To.Name)
+		for i := range edge.Sources {
+			source := &edge.Sources[i]
+			fmt.Fprintf(w, "%s\tby %s at %s\n", indent, source, source.Spec.DeclRange)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// WriteDOT writes the deployments of root and the edges of graph to w in
+// the Graphviz DOT language.
+// Deployments are filled by framework and outlined by type; Chronos jobs
+// are drawn as ellipses and Marathon apps as boxes.
+// Edges which wait for healthy are solid, and the rest are dashed.
+func WriteDOT(w io.Writer, root *model.Root, graph *model.Graph, opts Options) error {
+	l := newLayout(root, opts)
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "digraph mesosdef {\n")
+	fmt.Fprintf(out, "\tnode [style=filled, penwidth=2];\n")
+	for i, c := range l.clusters {
+		fmt.Fprintf(out, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(out, "\t\tlabel=%s;\n", dotQuote(c.label))
+		for _, n := range c.nodes {
+			writeDOTNode(out, "\t\t", n)
+		}
+		fmt.Fprintf(out, "\t}\n")
+	}
+	for _, n := range l.unclustered {
+		writeDOTNode(out, "\t", n)
+	}
+	for _, edge := range graph.Edges() {
+		style := "dashed"
+		if edge.WaitForHealthy {
+			style = "solid"
+		}
+		var sources []string
+		for i := range edge.Sources {
+			sources = append(sources, edge.Sources[i].String())
+		}
+		fmt.Fprintf(out, "\t%s -> %s [style=%s, tooltip=%s];\n", l.byRef[edge.From].id, l.byRef[edge.To].id,
+			style, dotQuote(strings.Join(sources, "\n")))
+	}
+	fmt.Fprintf(out, "}\n")
+	return out.Flush()
+}
+
+// writeDOTNode writes the statement declaring n.
+func writeDOTNode(out io.Writer, indent string, n *node) {
+	shape := "box"
+	if n.isSchedule {
+		shape = "ellipse"
+	}
+	fmt.Fprintf(out, "%s%s [label=%s, shape=%s, fillcolor=%s, color=%s, tooltip=%s];\n", indent, n.id,
+		dotQuote(n.label()), shape, dotQuote(n.fillColor), dotQuote(n.lineColor),
+		dotQuote(fmt.Sprintf("framework %s.%s", n.framework.Type, n.framework.Name)))
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,100 @@
+// Package export writes dependency graphs in formats understood by other
+// tools, such as Graphviz DOT, Mermaid, and JSON.
+// Edges point from each deployment to the deployments it depends on.
+package export
+
+import (
+	"fmt"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Options controls how a graph is written.
+type Options struct {
+	// ClusterByLabel groups deployments by their first label, in the formats
+	// which support it.
+	ClusterByLabel bool
+}
+
+// frameworkColors are the fill colors of deployments, assigned to
+// frameworks in declaration order.
+var frameworkColors = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3",
+	"#fdb462", "#b3de69", "#fccde5", "#d9d9d9", "#bc80bd",
+}
+
+// typeColors are the border colors of deployments of each type.
+var typeColors = map[string]string{
+	"marathon_app": "#1f78b4",
+	"chronos_job":  "#e31a1c",
+}
+
+// node is a deployment along with how it should be drawn.
+type node struct {
+	id         string
+	ref        model.DeploymentRef
+	framework  model.FrameworkRef
+	labels     []string
+	fillColor  string
+	lineColor  string
+	isSchedule bool
+}
+
+// label returns the text to draw for n.
+func (n *node) label() string {
+	return fmt.Sprintf("%s.%s", n.ref.Type, n.ref.Name)
+}
+
+// cluster is a group of nodes sharing a label.
+type cluster struct {
+	label string
+	nodes []*node
+}
+
+// layout holds the nodes of a graph, grouped into clusters if requested.
+type layout struct {
+	nodes    []*node
+	byRef    map[model.DeploymentRef]*node
+	clusters []cluster
+	// unclustered holds the nodes not in any cluster
+	unclustered []*node
+}
+
+// newLayout assigns identifiers and colors to the deployments of root.
+func newLayout(root *model.Root, opts Options) *layout {
+	frameworkIndex := make(map[model.FrameworkRef]int, len(root.Frameworks))
+	for i := range root.Frameworks {
+		frameworkIndex[root.Frameworks[i].Ref()] = i
+	}
+	l := &layout{
+		byRef: make(map[model.DeploymentRef]*node, len(root.Deployments)),
+	}
+	clusterIndex := make(map[string]int)
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		framework, _ := deployment.FrameworkRef()
+		n := &node{
+			id:         fmt.Sprintf("n%d", i),
+			ref:        deployment.Ref(),
+			framework:  framework,
+			labels:     deployment.Labels,
+			fillColor:  frameworkColors[frameworkIndex[framework]%len(frameworkColors)],
+			lineColor:  typeColors[deployment.Type],
+			isSchedule: deployment.Type == "chronos_job",
+		}
+		l.nodes = append(l.nodes, n)
+		l.byRef[n.ref] = n
+		if !opts.ClusterByLabel || len(n.labels) == 0 {
+			l.unclustered = append(l.unclustered, n)
+			continue
+		}
+		j, ok := clusterIndex[n.labels[0]]
+		if !ok {
+			j = len(l.clusters)
+			clusterIndex[n.labels[0]] = j
+			l.clusters = append(l.clusters, cluster{label: n.labels[0]})
+		}
+		l.clusters[j].nodes = append(l.clusters[j].nodes, n)
+	}
+	return l
+}
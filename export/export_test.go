@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kbolino/mesosdef/model"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// testConfig has quotes and brackets in deployment names, labels for
+// clustering, and edges which do and do not wait for healthy.
+const testConfig = `
+framework "marathon" "default" {
+  mesos_name = "marathon"
+  masters    = []
+}
+
+framework "chronos" "default" {
+  mesos_name = "chronos"
+  masters    = []
+}
+
+deployment "marathon_app" "web" {
+  for_each   = { us = "frontend", "say \"hi\"" = "greeter" }
+  labels     = [each.value]
+  definition = {}
+  dependency {
+    type             = "marathon_app"
+    name             = "db"
+    wait_for_healthy = true
+  }
+}
+
+deployment "marathon_app" "db" {
+  labels     = ["storage", "frontend"]
+  definition = {}
+  dependency_of {
+    type = "chronos_job"
+    name = "backup"
+  }
+}
+
+deployment "marathon_app" "cache" {
+  definition = {}
+}
+
+deployment "chronos_job" "backup" {
+  count      = 1
+  labels     = ["storage"]
+  definition = {}
+}
+`
+
+func TestWrite(t *testing.T) {
+	file, diags := hclparse.NewParser().ParseHCL([]byte(testConfig), "test.hcl")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	var root model.Root
+	if diags := model.DecodeBody(file.Body, nil, &root); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if diags := root.Validate(); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	var graph model.Graph
+	if err := graph.Build(root.Deployments...); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		golden string
+		write  func(w io.Writer, root *model.Root, graph *model.Graph, opts Options) error
+		opts   Options
+	}{
+		{golden: "graph.dot", write: WriteDOT},
+		{golden: "graph-clustered.dot", write: WriteDOT, opts: Options{ClusterByLabel: true}},
+		{golden: "graph.mmd", write: WriteMermaid},
+		{golden: "graph-clustered.mmd", write: WriteMermaid, opts: Options{ClusterByLabel: true}},
+		{golden: "graph.json", write: WriteJSON, opts: Options{ClusterByLabel: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := test.write(&buf, &root, &graph, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := ioutil.ReadFile(filepath.Join("testdata", test.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := buf.String(); actual != string(expected) {
+				t.Errorf("got:\n%s\nwant:\n%s", actual, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// jsonGraph is the document written by WriteJSON.
+type jsonGraph struct {
+	Deployments []jsonDeployment `json:"deployments"`
+	Edges       []jsonEdge       `json:"edges"`
+}
+
+type jsonDeployment struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Framework string   `json:"framework"`
+	Labels    []string `json:"labels"`
+}
+
+type jsonEdge struct {
+	From           string       `json:"from"`
+	To             string       `json:"to"`
+	WaitForHealthy bool         `json:"wait_for_healthy"`
+	Sources        []jsonSource `json:"sources"`
+}
+
+type jsonSource struct {
+	DeclaredBy  string `json:"declared_by"`
+	Block       string `json:"block"`
+	Description string `json:"description"`
+	Range       string `json:"range"`
+}
+
+// WriteJSON writes the deployments of root and the edges of graph to w as a
+// JSON document, with the sources of every edge.
+// Deployments and edges refer to deployments by their type.name form.
+// Clustering is left to the consumer, since the labels of each deployment are
+// included.
+func WriteJSON(w io.Writer, root *model.Root, graph *model.Graph, opts Options) error {
+	doc := jsonGraph{
+		Deployments: []jsonDeployment{},
+		Edges:       []jsonEdge{},
+	}
+	for _, n := range newLayout(root, opts).nodes {
+		labels := n.labels
+		if labels == nil {
+			labels = []string{}
+		}
+		doc.Deployments = append(doc.Deployments, jsonDeployment{
+			ID:        n.label(),
+			Type:      n.ref.Type,
+			Name:      n.ref.Name,
+			Framework: fmt.Sprintf("%s.%s", n.framework.Type, n.framework.Name),
+			Labels:    labels,
+		})
+	}
+	for _, edge := range graph.Edges() {
+		out := jsonEdge{
+			From:           fmt.Sprintf("%s.%s", edge.From.Type, edge.From.Name),
+			To:             fmt.Sprintf("%s.%s", edge.To.Type, edge.To.Name),
+			WaitForHealthy: edge.WaitForHealthy,
+		}
+		for i := range edge.Sources {
+			source := &edge.Sources[i]
+			block := "dependency"
+			if source.Inverse {
+				block = "dependency_of"
+			}
+			out.Sources = append(out.Sources, jsonSource{
+				DeclaredBy:  fmt.Sprintf("%s.%s", source.DeclaredBy.Type, source.DeclaredBy.Name),
+				Block:       block,
+				Description: source.String(),
+				Range:       source.Spec.DeclRange.String(),
+			})
+		}
+		doc.Edges = append(doc.Edges, out)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&doc)
+}
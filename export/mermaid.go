@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// WriteMermaid writes the deployments of root and the edges of graph to w as
+// a Mermaid flowchart.
+// Deployments are styled as by WriteDOT, except that Chronos jobs are drawn
+// as stadiums; edges which do not wait for healthy are dotted.
+func WriteMermaid(w io.Writer, root *model.Root, graph *model.Graph, opts Options) error {
+	l := newLayout(root, opts)
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "flowchart TB\n")
+	for i, c := range l.clusters {
+		fmt.Fprintf(out, "\tsubgraph cluster_%d [\"%s\"]\n", i, mermaidEscape(c.label))
+		for _, n := range c.nodes {
+			writeMermaidNode(out, "\t\t", n)
+		}
+		fmt.Fprintf(out, "\tend\n")
+	}
+	for _, n := range l.unclustered {
+		writeMermaidNode(out, "\t", n)
+	}
+	for _, edge := range graph.Edges() {
+		arrow := "-.->"
+		if edge.WaitForHealthy {
+			arrow = "-->"
+		}
+		fmt.Fprintf(out, "\t%s %s %s\n", l.byRef[edge.From].id, arrow, l.byRef[edge.To].id)
+	}
+	for _, n := range l.nodes {
+		fmt.Fprintf(out, "\tstyle %s fill:%s,stroke:%s,stroke-width:2px\n", n.id, n.fillColor, n.lineColor)
+	}
+	return out.Flush()
+}
+
+// writeMermaidNode writes the statement declaring n.
+func writeMermaidNode(out io.Writer, indent string, n *node) {
+	open, close := "[", "]"
+	if n.isSchedule {
+		open, close = "([", "])"
+	}
+	fmt.Fprintf(out, "%s%s%s\"%s\"%s\n", indent, n.id, open, mermaidEscape(n.label()), close)
+}
+
+// mermaidEscape replaces the characters in s which cannot appear in a
+// quoted Mermaid label with entity codes.
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
@@ -3,7 +3,9 @@ module github.com/kbolino/mesosdef
 go 1.14
 
 require (
+	github.com/agext/levenshtein v1.2.1
 	github.com/hashicorp/hcl/v2 v2.6.0
 	github.com/yourbasic/graph v0.0.0-20170921192928-40eb135c0b26
-	github.com/zclconf/go-cty v1.2.0
+	github.com/zclconf/go-cty v1.8.4
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/export"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// graphMain is the entry point for the graph subcommand.
+func graphMain(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	var format string
+	var opts export.Options
+	var reduce, criticalPath bool
+	var reports stringSliceValue
+	flags.StringVar(&format, "format", "dot", "output format, one of dot, mermaid, or json")
+	flags.BoolVar(&opts.ClusterByLabel, "cluster", false, "group deployments by their first label")
+	flags.BoolVar(&reduce, "reduce", true, "leave out edges implied by other dependencies")
+	flags.BoolVar(&criticalPath, "critical-path", false, "print the critical path and minimum deployment time instead")
+	flags.Var(&reports, "report", "estimate durations from a report written by -report, can be repeated")
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s graph [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Writes the dependency graph to standard output.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	var write func(w io.Writer, root *model.Root, graph *model.Graph, opts export.Options) error
+	switch format {
+	case "dot":
+		write = export.WriteDOT
+	case "mermaid":
+		write = export.WriteMermaid
+	case "json":
+		write = export.WriteJSON
+	default:
+		return fmt.Errorf("unknown graph format \"%s\", only \"dot\", \"mermaid\", and \"json\" are supported",
+			format)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	graph := &cfg.graph
+	if criticalPath {
+		return printCriticalPath(cfg, reports)
+	}
+	if reduce {
+		if graph, err = graph.TransitiveReduction(); err != nil {
+			return err
+		}
+	}
+	return write(os.Stdout, &cfg.root, graph, opts)
+}
+
+// printCriticalPath prints the critical path of the graph of cfg, with
+// durations estimated by loadEstimates.
+func printCriticalPath(cfg *config, reportFiles []string) error {
+	estimates, unestimated, err := loadEstimates(cfg, reportFiles)
+	if err != nil {
+		return err
+	}
+	path, err := cfg.graph.CriticalPath(estimates)
+	if err != nil {
+		return err
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "START\tDEPLOYED\tHEALTHY\tDEPLOYMENT\n")
+	for i, step := range path.Steps {
+		after := ""
+		if i != 0 {
+			previous := path.Steps[i-1].Deployment
+			after = "immediately after"
+			if step.WaitForHealthy {
+				after = "after waiting for"
+			}
+			after = fmt.Sprintf(" %s %s.%s", after, previous.Type, previous.Name)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s.%s%s\n", step.Start.Round(time.Millisecond),
+			step.Deployed.Round(time.Millisecond), step.Healthy.Round(time.Millisecond), step.Deployment.Type,
+			step.Deployment.Name, after)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("theoretical minimum deployment time: %s\n", path.Duration.Round(time.Millisecond))
+	if len(unestimated) != 0 {
+		fmt.Printf("%d deployment(s) have no expected times and were counted as taking none: %s\n",
+			len(unestimated), strings.Join(unestimated, ", "))
+	}
+	return nil
+}
+
+// loadEstimates estimates the durations of the deployments of cfg from the
+// named report files and then from the expected times declared by each
+// deployment, which take precedence.
+// Also returns the type.name form of every deployment with no estimate.
+func loadEstimates(cfg *config, reportFiles []string) (map[model.DeploymentRef]model.Estimate, []string, error) {
+	var reports []*deploy.Report
+	for _, filename := range reportFiles {
+		report, err := deploy.ReadReport(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		reports = append(reports, report)
+	}
+	estimates := deploy.Estimates(reports...)
+	var unestimated []string
+	for i := range cfg.root.Deployments {
+		deployment := &cfg.root.Deployments[i]
+		ref := deployment.Ref()
+		estimate, ok := estimates[ref]
+		// the expected times have already been validated
+		if deployment.ExpectedDeployTime != "" {
+			estimate.Deploy, _ = time.ParseDuration(deployment.ExpectedDeployTime)
+			ok = true
+		}
+		if deployment.ExpectedHealthTime != "" {
+			estimate.Health, _ = time.ParseDuration(deployment.ExpectedHealthTime)
+			ok = true
+		}
+		if !ok {
+			unestimated = append(unestimated, fmt.Sprintf("%s.%s", ref.Type, ref.Name))
+			continue
+		}
+		estimates[ref] = estimate
+	}
+	return estimates, unestimated, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kbolino/mesosdef/lint"
+)
+
+// lintMain is the entry point for the lint subcommand.
+func lintMain(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	var disabled stringSliceValue
+	var listRules bool
+	flags.Var(&disabled, "disable", "disable a lint rule by name, can be repeated")
+	flags.BoolVar(&listRules, "list", false, "list all lint rules and exit")
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s lint [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Checks a valid configuration for suspicious dependencies.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if listRules {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%-22s %s\n", rule.Name, rule.Description)
+		}
+		return nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var disabledNames []string
+	for _, value := range disabled {
+		disabledNames = append(disabledNames, strings.Split(value, ",")...)
+	}
+	diags, err := lint.Run(&cfg.root, &cfg.graph, disabledNames)
+	if err != nil {
+		return err
+	}
+	if err := cfg.writeDiagnostics(diags); err != nil {
+		return err
+	}
+	if diags.HasErrors() {
+		return fmt.Errorf("lint found %d problem(s), including errors", len(diags))
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kbolino/mesosdef/model"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// Rule is a named check for configurations that are valid but probably
+// wrong.
+type Rule struct {
+	Name        string
+	Description string
+	check       func(c *checkContext) hcl.Diagnostics
+}
+
+// rules is the list of all rules, sorted by name.
+var rules = []Rule{
+	{
+		Name:        "conflicting_wait",
+		Description: "the same dependency is declared more than once with different wait_for_healthy values",
+		check:       checkConflictingWait,
+	},
+	{
+		Name:        "dependency_cycle",
+		Description: "deployments depend on each other, directly or indirectly",
+		check:       checkDependencyCycle,
+	},
+	{
+		Name:        "redundant_dependency",
+		Description: "a named dependency is already implied by other dependencies",
+		check:       checkRedundantDependency,
+	},
+	{
+		Name:        "self_dependency",
+		Description: "a dependency or dependency_of block matches the deployment declaring it",
+		check:       checkSelfDependency,
+	},
+	{
+		Name:        "unmatched_dependency",
+		Description: "a dependency or dependency_of block with filters matches no deployments",
+		check:       checkUnmatchedDependency,
+	},
+}
+
+// Rules returns all lint rules, sorted by name.
+func Rules() []Rule {
+	result := make([]Rule, len(rules))
+	copy(result, rules)
+	return result
+}
+
+// Run checks root and its dependency graph against every rule not named in
+// disabled, returning the problems found as diagnostics.
+// The summary of each diagnostic ends with the name of the rule in brackets.
+// Returns a non-nil error if disabled names a rule that does not exist.
+func Run(root *model.Root, graph *model.Graph, disabled []string) (hcl.Diagnostics, error) {
+	disabledSet := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		found := false
+		for _, rule := range rules {
+			if rule.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown lint rule \"%s\"", name)
+		}
+		disabledSet[name] = true
+	}
+	c, err := newCheckContext(root, graph)
+	if err != nil {
+		return nil, err
+	}
+	var diags hcl.Diagnostics
+	for _, rule := range rules {
+		if disabledSet[rule.Name] {
+			continue
+		}
+		for _, diag := range rule.check(c) {
+			diag.Summary = fmt.Sprintf("%s [%s]", diag.Summary, rule.Name)
+			diags = append(diags, diag)
+		}
+	}
+	return diags, nil
+}
+
+// checkContext holds the data shared by all rules.
+type checkContext struct {
+	root  *model.Root
+	graph *model.Graph
+	// targets holds the targets of each spec
+	targets map[*model.DependencySpec][]int
+	// indexByRef maps each deployment to its index in root.Deployments
+	indexByRef map[model.DeploymentRef]int
+}
+
+// newCheckContext resolves the targets of all dependency specs in root.
+func newCheckContext(root *model.Root, graph *model.Graph) (*checkContext, error) {
+	c := &checkContext{
+		root:       root,
+		graph:      graph,
+		targets:    make(map[*model.DependencySpec][]int),
+		indexByRef: make(map[model.DeploymentRef]int, len(root.Deployments)),
+	}
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		c.indexByRef[deployment.Ref()] = i
+		for j := range deployment.Dependencies {
+			spec := &deployment.Dependencies[j]
+			targets, err := spec.Targets(root.Deployments)
+			if err != nil {
+				return nil, fmt.Errorf("resolving dependency of %s.%s: %w", deployment.Type, deployment.Name, err)
+			}
+			c.targets[spec] = targets
+		}
+		for j := range deployment.DependencyOf {
+			spec := &deployment.DependencyOf[j]
+			targets, err := spec.Targets(root.Deployments)
+			if err != nil {
+				return nil, fmt.Errorf("resolving dependency_of of %s.%s: %w", deployment.Type, deployment.Name, err)
+			}
+			c.targets[spec] = targets
+		}
+	}
+	return c, nil
+}
+
+// name returns the type.name form of the deployment with index i.
+func (c *checkContext) name(i int) string {
+	deployment := &c.root.Deployments[i]
+	return fmt.Sprintf("%s.%s", deployment.Type, deployment.Name)
+}
+
+// forEachSpec calls f for every dependency spec, with the index of the
+// deployment declaring it and the kind of block it was declared by.
+func (c *checkContext) forEachSpec(f func(i int, kind string, spec *model.DependencySpec)) {
+	for i := range c.root.Deployments {
+		deployment := &c.root.Deployments[i]
+		for j := range deployment.Dependencies {
+			f(i, "dependency", &deployment.Dependencies[j])
+		}
+		for j := range deployment.DependencyOf {
+			f(i, "dependency_of", &deployment.DependencyOf[j])
+		}
+	}
+}
+
+// checkUnmatchedDependency implements the unmatched_dependency rule.
+// Specs with a name are ignored, since validation requires them to match.
+func checkUnmatchedDependency(c *checkContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	c.forEachSpec(func(i int, kind string, spec *model.DependencySpec) {
+		if spec.Name != "" {
+			return
+		}
+		for _, k := range c.targets[spec] {
+			if k != i {
+				return
+			}
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  fmt.Sprintf("Block %s matches no deployments", kind),
+			Detail: fmt.Sprintf("The %s block of %s matches no other deployments, so it has no effect.",
+				kind, c.name(i)),
+			Subject: spec.DeclRange.Ptr(),
+		})
+	})
+	return diags
+}
+
+// checkSelfDependency implements the self_dependency rule.
+func checkSelfDependency(c *checkContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	c.forEachSpec(func(i int, kind string, spec *model.DependencySpec) {
+		for _, k := range c.targets[spec] {
+			if k == i {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Deployment depends on itself",
+					Detail: fmt.Sprintf("The %s block of %s matches %s itself, so it can never be deployed; "+
+						"add a filter to exclude it.", kind, c.name(i), c.name(i)),
+					Subject: spec.DeclRange.Ptr(),
+				})
+				return
+			}
+		}
+	})
+	return diags
+}
+
+// checkConflictingWait implements the conflicting_wait rule.
+func checkConflictingWait(c *checkContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, edge := range c.graph.Edges() {
+		first := &edge.Sources[0]
+		for i := range edge.Sources[1:] {
+			source := &edge.Sources[1+i]
+			if source.Spec.WaitForHealthy == first.Spec.WaitForHealthy {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Conflicting wait_for_healthy",
+				Detail: fmt.Sprintf("The dependency of %s.%s on %s.%s is declared with wait_for_healthy = %t "+
+					"here and with wait_for_healthy = %t by the %s at %s; it will wait for healthy.",
+					edge.From.Type, edge.From.Name, edge.To.Type, edge.To.Name, source.Spec.WaitForHealthy,
+					first.Spec.WaitForHealthy, first, first.Spec.DeclRange),
+				Subject: source.Spec.DeclRange.Ptr(),
+			})
+			break
+		}
+	}
+	return diags
+}
+
+// checkRedundantDependency implements the redundant_dependency rule.
+// A named dependency is redundant if its target is reachable by a longer
+// path; if it waits for its target to become healthy, then every edge of
+// the path must also wait.
+func checkRedundantDependency(c *checkContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for i := range c.root.Deployments {
+		deployment := &c.root.Deployments[i]
+		for j := range deployment.Dependencies {
+			spec := &deployment.Dependencies[j]
+			if spec.Name == "" {
+				continue
+			}
+			for _, k := range c.targets[spec] {
+				via, ok := c.indirectPath(i, k, spec.WaitForHealthy)
+				if !ok {
+					continue
+				}
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagWarning,
+					Summary:  "Redundant dependency",
+					Detail: fmt.Sprintf("%s already depends on %s through %s, so this dependency can be removed.",
+						c.name(i), c.name(k), strings.Join(via, " -> ")),
+					Subject: spec.NameRange.Ptr(),
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// indirectPath searches for a path of length two or more from deployment i to
+// deployment k in the graph, using only edges that wait for healthy if
+// waitOnly is true, and returns the names of the deployments along it.
+func (c *checkContext) indirectPath(i, k int, waitOnly bool) ([]string, bool) {
+	dependencies := func(v int) []int {
+		refs, err := c.graph.Dependencies(c.root.Deployments[v].Ref())
+		if err != nil {
+			return nil
+		}
+		var result []int
+		for _, ref := range refs {
+			if waitOnly && !ref.WaitForHealthy {
+				continue
+			}
+			result = append(result, c.indexByRef[ref.DeploymentRef()])
+		}
+		sort.Ints(result)
+		return result
+	}
+	// breadth-first search starting from the dependencies of i other than k
+	parent := map[int]int{i: -1}
+	var queue []int
+	for _, v := range dependencies(i) {
+		if v != k && v != i {
+			parent[v] = i
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) != 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range dependencies(v) {
+			if _, seen := parent[w]; seen {
+				continue
+			}
+			parent[w] = v
+			if w == k {
+				var path []string
+				for u := k; u != -1; u = parent[u] {
+					path = append([]string{c.name(u)}, path...)
+				}
+				return path, true
+			}
+			queue = append(queue, w)
+		}
+	}
+	return nil, false
+}
+
+// checkDependencyCycle implements the dependency_cycle rule.
+// Deployments which depend on themselves are left to self_dependency.
+func checkDependencyCycle(c *checkContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, cycle := range c.graph.Cycles() {
+		if len(cycle) == 1 {
+			continue
+		}
+		var hops []string
+		for _, edge := range cycle {
+			source := &edge.Sources[0]
+			hops = append(hops, fmt.Sprintf("%s.%s depends on %s.%s by the %s at %s.", edge.From.Type,
+				edge.From.Name, edge.To.Type, edge.To.Name, source, source.Spec.DeclRange))
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Dependency cycle",
+			Detail:   fmt.Sprintf("Deployments depend on each other: %s. %s", cycle, strings.Join(hops, " ")),
+			Subject:  cycle[0].Sources[0].Spec.DeclRange.Ptr(),
+		})
+	}
+	return diags
+}
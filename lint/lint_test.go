@@ -0,0 +1,272 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/model"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		disabled []string
+		diags    []string
+		err      string
+	}{
+		{
+			name: "conflicting_wait",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type             = "marathon_app"
+    name             = "b"
+    wait_for_healthy = true
+  }
+  dependency {
+    type = "marathon_app"
+    filter {
+      key   = "labels"
+      value = "db"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["db"]
+}`,
+			diags: []string{"7:3 Conflicting wait_for_healthy [conflicting_wait]"},
+		},
+		{
+			name: "consistent wait",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type             = "marathon_app"
+    name             = "b"
+    wait_for_healthy = true
+  }
+  dependency {
+    type             = "marathon_app"
+    wait_for_healthy = true
+    filter {
+      key   = "labels"
+      value = "db"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["db"]
+}`,
+		},
+		{
+			name: "dependency_cycle",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type = "marathon_app"
+    name = "b"
+  }
+}
+deployment "marathon_app" "b" {
+  dependency {
+    type = "marathon_app"
+    name = "a"
+  }
+}`,
+			diags: []string{"2:3 Dependency cycle [dependency_cycle]"},
+		},
+		{
+			name: "no cycle",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type = "marathon_app"
+    name = "b"
+  }
+}
+deployment "marathon_app" "b" {
+  dependency_of {
+    type = "marathon_app"
+    name = "a"
+  }
+}`,
+		},
+		{
+			name: "redundant_dependency",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type = "marathon_app"
+    name = "b"
+  }
+  dependency {
+    type = "marathon_app"
+    name = "c"
+  }
+}
+deployment "marathon_app" "b" {
+  dependency {
+    type = "marathon_app"
+    name = "c"
+  }
+}
+deployment "marathon_app" "c" {}`,
+			diags: []string{"8:12 Redundant dependency [redundant_dependency]"},
+		},
+		{
+			name: "wait is not redundant",
+			src: `deployment "marathon_app" "a" {
+  dependency {
+    type = "marathon_app"
+    name = "b"
+  }
+  dependency {
+    type             = "marathon_app"
+    name             = "c"
+    wait_for_healthy = true
+  }
+}
+deployment "marathon_app" "b" {
+  dependency {
+    type = "marathon_app"
+    name = "c"
+  }
+}
+deployment "marathon_app" "c" {}`,
+		},
+		{
+			name: "self_dependency",
+			src: `deployment "marathon_app" "a" {
+  labels = ["web"]
+  dependency {
+    type = "*"
+    filter {
+      key   = "labels"
+      value = "web"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["web"]
+}`,
+			diags: []string{"3:3 Deployment depends on itself [self_dependency]"},
+		},
+		{
+			name: "self excluded",
+			src: `deployment "marathon_app" "a" {
+  labels = ["web"]
+  dependency {
+    type = "*"
+    filter {
+      key   = "labels"
+      value = "web"
+    }
+    filter {
+      key    = "name"
+      value  = "a"
+      negate = true
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["web"]
+}`,
+		},
+		{
+			name: "unmatched_dependency",
+			src: `deployment "marathon_app" "a" {
+  dependency_of {
+    type = "chronos_job"
+    filter {
+      key   = "labels"
+      value = "web"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["web"]
+}`,
+			diags: []string{"2:3 Block dependency_of matches no deployments [unmatched_dependency]"},
+		},
+		{
+			name: "matched dependency",
+			src: `deployment "marathon_app" "a" {
+  dependency_of {
+    type = "marathon_app"
+    filter {
+      key   = "labels"
+      value = "web"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["web"]
+}`,
+		},
+		{
+			name: "disabled",
+			src: `deployment "marathon_app" "a" {
+  labels = ["web"]
+  dependency {
+    type = "*"
+    filter {
+      key   = "labels"
+      value = "web"
+    }
+  }
+}
+deployment "marathon_app" "b" {
+  labels = ["web"]
+}`,
+			disabled: []string{"self_dependency"},
+		},
+		{
+			name:     "unknown rule",
+			src:      `deployment "marathon_app" "a" {}`,
+			disabled: []string{"self_dependency", "self_dependncy"},
+			err:      "unknown lint rule \"self_dependncy\"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(test.src), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			var root model.Root
+			if diags := model.DecodeBody(file.Body, nil, &root); diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			var graph model.Graph
+			if err := graph.Build(root.Deployments...); err != nil {
+				t.Fatal(err)
+			}
+			diags, err := Run(&root, &graph, test.disabled)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			actual := make([]string, len(diags))
+			for i, diag := range diags {
+				actual[i] = fmt.Sprintf("%d:%d %s", diag.Subject.Start.Line, diag.Subject.Start.Column, diag.Summary)
+			}
+			if strings.Join(actual, ", ") != strings.Join(test.diags, ", ") {
+				t.Errorf("got diagnostics %q, want %q", actual, test.diags)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	var names []string
+	for _, rule := range Rules() {
+		names = append(names, rule.Name)
+	}
+	expected := "conflicting_wait dependency_cycle redundant_dependency self_dependency unmatched_dependency"
+	if actual := strings.Join(names, " "); actual != expected {
+		t.Errorf("got rules %s, want %s", actual, expected)
+	}
+}
@@ -1,227 +1,114 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"math/rand"
 	"os"
+	"sort"
 	"strings"
-	"sync"
-	"time"
+)
 
-	"github.com/kbolino/mesosdef/deploy"
-	"github.com/kbolino/mesosdef/model"
+// version is the version of mesosdef, which is recorded in saved plans.
+const version = "0.2.0"
 
-	hcl "github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsimple"
-	"github.com/zclconf/go-cty/cty"
+// Exit codes shared by all subcommands.
+const (
+	exitSuccess = 0
+	exitError   = 1
+	exitChanges = 2
 )
 
-var (
-	flagDeployTimeout int
-	flagDryRun        bool
-	flagFile          string
-	flagMaxDeploy     int
-	flagNoenv         bool
-	flagVars          stringSliceValue
-	flagWaitTimeout   int
-)
+// errChanges is returned by a subcommand which succeeded and found changes
+// to be made, so that mesosdef exits with exitChanges.
+var errChanges = errors.New("changes present")
 
-func main() {
-	// set up flags
-	flag.IntVar(&flagDeployTimeout, "deployTimeout", 30, "timeout for deployment requests, in seconds")
-	flag.BoolVar(&flagDryRun, "dryRun", false, "check files and produce graph, but do not deploy")
-	flag.StringVar(&flagFile, "file", "", "file to parse")
-	flag.IntVar(&flagMaxDeploy, "maxDeploy", 5, "maximum number of simultaneous deployments")
-	flag.BoolVar(&flagNoenv, "noenv", false, "do not get variables from environment")
-	flag.Var(&flagVars, "var", "set a variable var=value, can be repeated")
-	flag.IntVar(&flagWaitTimeout, "waitTimeout", 300, "timeout for waiting until healthy, in seconds")
-	flag.Parse()
-	// run
-	if err := run(); err != nil {
-		fmt.Fprintf(os.Stderr, "FATAL: %s\n", err)
-		os.Exit(1)
+// errUsage is returned by parseFlags if the command line of a subcommand is
+// invalid, which the flag set has already reported, so that mesosdef exits
+// with exitError without reporting it again.
+var errUsage = errors.New("invalid command line")
+
+// parseFlags parses args with flags, which must use flag.ContinueOnError.
+// Returns flag.ErrHelp if help was requested, errUsage if args are invalid,
+// and nil otherwise.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return err
+	} else if err != nil {
+		return errUsage
 	}
+	return nil
 }
 
-func run() error {
-	// parse variables from env & args and add to EvalContext
-	varCount := len(flagVars)
-	environ := os.Environ()
-	if !flagNoenv {
-		varCount += len(environ)
-	}
-	ctx := hcl.EvalContext{
-		Variables: make(map[string]cty.Value, varCount),
-	}
-	if !flagNoenv {
-		for _, envDef := range environ {
-			parts := strings.SplitN(envDef, "=", 2)
-			if len(parts) != 2 {
-				continue
-			}
-			name := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			if !model.IsValidIdentifier(name) {
-				continue
-			}
-			ctx.Variables[name] = cty.StringVal(value)
-		}
-	}
-	if len(flagVars) != 0 {
-		for _, varDef := range flagVars {
-			parts := strings.SplitN(varDef, "=", 2)
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid variable declaration \"%s\"", varDef)
-			}
-			name := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			if !model.IsValidIdentifier(name) {
-				return fmt.Errorf("invalid variable name \"%s\"", name)
-			}
-			if len(value) == 0 {
-				delete(ctx.Variables, name)
-			} else {
-				ctx.Variables[name] = cty.StringVal(value)
-			}
-		}
-	}
-	// parse declaration file
-	var root model.Root
-	if err := hclsimple.DecodeFile(flagFile, &ctx, &root); err != nil {
-		return fmt.Errorf("decoding file \"%s\": %w", flagFile, err)
-	}
-	// validate and index frameworks
-	frameworksByRef := make(map[model.FrameworkRef]*model.Framework)
-	for i := range root.Frameworks {
-		framework := &root.Frameworks[i]
-		switch framework.Type {
-		case "marathon", "chronos":
-			// ok
-		default:
-			return fmt.Errorf("invalid framework type \"%s\"", framework.Type)
-		}
-		if !model.IsValidIdentifier(framework.Name) {
-			return fmt.Errorf("invalid framework name \"%s\"", framework.Name)
-		}
-		if _, exists := frameworksByRef[framework.Ref()]; exists {
-			return fmt.Errorf("duplicate framework %s.%s", framework.Type, framework.Name)
-		}
-		frameworksByRef[framework.Ref()] = framework
-	}
-	// validate and index deployments
-	deploymentsByRef := make(map[model.DeploymentRef]*model.Deployment)
-	for i := range root.Deployments {
-		deployment := &root.Deployments[i]
-		frameworkType := ""
-		switch deployment.Type {
-		case "marathon_app":
-			frameworkType = "marathon"
-		case "chronos_job":
-			frameworkType = "chronos"
-		default:
-			return fmt.Errorf("invalid deployment type \"%s\"", deployment.Type)
-		}
-		if !model.IsValidIdentifier(deployment.Name) {
-			return fmt.Errorf("invalid deployment name \"%s\"", deployment.Name)
-		}
-		if _, exists := deploymentsByRef[deployment.Ref()]; exists {
-			return fmt.Errorf("duplicate deployment %s.%s", deployment.Type, deployment.Name)
-		}
-		frameworkName := deployment.Framework
-		if frameworkName == "" {
-			frameworkName = "default"
-		}
-		frameworkRef := model.FrameworkRef{
-			Type: frameworkType,
-			Name: frameworkName,
-		}
-		if _, exists := frameworksByRef[frameworkRef]; !exists {
-			return fmt.Errorf("no framework %s.%s defined for deployment %s.%s", frameworkType, frameworkName,
-				deployment.Type, deployment.Name)
-		}
-		deploymentsByRef[deployment.Ref()] = deployment
-	}
-	// create deployment dependency graph
-	var graph model.Graph
-	graph.Build(root.Deployments...)
-	// check for dependency cycles
-	cycles := graph.Cycles()
-	if len(cycles) != 0 {
-		var message strings.Builder
-		message.WriteString("dependency cycle(s) detected:\n")
-		for _, cycle := range cycles {
-			var deploymentNames []string
-			for _, deployment := range cycle {
-				deploymentNames = append(deploymentNames, fmt.Sprintf("%s.%s", deployment.Type, deployment.Name))
-			}
-			fmt.Fprintf(&message, "\t=> %s <=\n", strings.Join(deploymentNames, " <==> "))
+// command is a subcommand of mesosdef.
+type command struct {
+	// main is the entry point, which is given the arguments following the
+	// name of the subcommand.
+	main func(args []string) error
+	// description is a one-line summary for the usage message.
+	description string
+}
+
+// commands maps the name of each subcommand to the subcommand.
+var commands = map[string]command{
+	"apply":      {applyMain, "deploy the configuration"},
+	"destroy":    {destroyMain, "remove deployments in reverse dependency order"},
+	"definition": {definitionMain, "print the final definition of a deployment"},
+	"explain":    {explainMain, "explain why one deployment depends on another"},
+	"graph":      {graphMain, "write the dependency graph or its critical path"},
+	"lint":       {lintMain, "check the configuration for suspicious dependencies"},
+	"plan":       {planMain, "print the deployments apply would make, in waves"},
+	"simulate":   {simulateMain, "compare values of -maxDeploy on a virtual clock"},
+	"validate":   {validateMain, "check the configuration without deploying it"},
+	"version":    {versionMain, "print the version of mesosdef"},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-help" || os.Args[1] == "-h" {
+		usage()
+		if len(os.Args) < 2 {
+			os.Exit(exitError)
 		}
-		return fmt.Errorf("%s", message.String())
+		return
 	}
-	// sort and print graph for dry run
-	if flagDryRun {
-		deployOrder, err := graph.DeployOrder()
-		if err != nil {
-			return err
-		}
-		for _, deployment := range deployOrder {
-			fmt.Printf("%s.%s\n", deployment.Type, deployment.Name)
-			dependencies, err := graph.Dependencies(deployment)
-			if err != nil {
-				return err
-			}
-			for _, dependency := range dependencies {
-				prefix := "immediately after"
-				if dependency.WaitForHealthy {
-					prefix = "after waiting for"
-				}
-				fmt.Printf("\t%s %s.%s\n", prefix, dependency.Type, dependency.Name)
-			}
-		}
-		return nil
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		fmt.Fprintf(os.Stderr, "FATAL: unknown command \"%s\"\n", os.Args[1])
+		os.Exit(exitError)
 	}
-	// run mock deployment
-	rand.Seed(time.Now().UnixNano())
-	deployer := &mockDeployer{
-		minDeployTime:      50 * time.Millisecond,
-		maxDeployTime:      250 * time.Millisecond,
-		deployErrorChance:  0.01,
-		minHealthyTime:     200 * time.Millisecond,
-		maxHealthyTime:     700 * time.Millisecond,
-		healthyErrorChance: 0.01,
+	if err := cmd.main(os.Args[2:]); err == errChanges {
+		os.Exit(exitChanges)
+	} else if err == flag.ErrHelp {
+		os.Exit(exitSuccess)
+	} else if err == errUsage {
+		os.Exit(exitError)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: %s\n", err)
+		os.Exit(exitError)
 	}
-	events := make(chan deploy.Event, 100)
-	graphDeployer, err := deploy.NewGraphDeployer(&graph, deployer, flagMaxDeploy)
-	if err != nil {
-		return fmt.Errorf("creating graph deployer: %w", err)
+	os.Exit(exitSuccess)
+}
+
+// usage writes the list of subcommands to stderr.
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for event := range events {
-			var otherPart string
-			if event.Err != nil {
-				otherPart = fmt.Sprintf(", error='%s'", event.Err.Error())
-			} else if event.Dependency.Deployment != nil {
-				deployRef := event.Dependency.Deployment.Ref()
-				otherPart = fmt.Sprintf(", dependency=%s.%s", deployRef.Type, deployRef.Name)
-			}
-			deployRef := event.Deployment.Ref()
-			timeFormat := "2006-01-02T15:04:05.000Z07:00"
-			fmt.Printf("%s workerID=%d queueLen=%-3d %-25s %s.%s%s\n", time.Now().Format(timeFormat),
-				event.WorkerID, len(events), event.Type, deployRef.Type, deployRef.Name, otherPart)
-		}
-	}()
-	deployErr := graphDeployer.Deploy(events)
-	wg.Wait()
-	stats := graphDeployer.Stats()
-	fmt.Printf("Result: %d successful and %d failed deployments of %d resources in %s\n", stats.SuccessfulDeployments,
-		stats.FailedDeployments, stats.TotalDeployments, stats.ElapsedTime.Truncate(time.Millisecond))
-	if deployErr != nil {
-		return fmt.Errorf("deploying graph: %w", deployErr)
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s%s\n", name, commands[name].description)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -help for the options of a command.\n"+
+		"Exits with %d on success, %d on error, or %d if plan found changes.\n",
+		os.Args[0], exitSuccess, exitError, exitChanges)
+}
+
+// versionMain is the entry point for the version subcommand.
+func versionMain(args []string) error {
+	fmt.Printf("mesosdef %s\n", version)
 	return nil
 }
 
@@ -237,32 +124,3 @@ func (v *stringSliceValue) Set(value string) error {
 	*v = append(*v, value)
 	return nil
 }
-
-type mockDeployer struct {
-	minDeployTime      time.Duration
-	maxDeployTime      time.Duration
-	deployErrorChance  float32
-	minHealthyTime     time.Duration
-	maxHealthyTime     time.Duration
-	healthyErrorChance float32
-}
-
-var _ deploy.Deployer = &mockDeployer{}
-
-func (d *mockDeployer) Deploy(ref model.DeploymentRef) error {
-	waitTime := d.minDeployTime + time.Duration(rand.Int63n(int64(d.maxDeployTime-d.minDeployTime)))
-	time.Sleep(waitTime)
-	if d.deployErrorChance != 0 && rand.Float32() < d.deployErrorChance {
-		return fmt.Errorf("mock error")
-	}
-	return nil
-}
-
-func (d *mockDeployer) WaitUntilHealthy(ref model.DeploymentRef) error {
-	waitTime := d.minHealthyTime + time.Duration(rand.Int63n(int64(d.maxHealthyTime-d.minHealthyTime)))
-	time.Sleep(waitTime)
-	if d.healthyErrorChance != 0 && rand.Float32() < d.healthyErrorChance {
-		return fmt.Errorf("mock error")
-	}
-	return nil
-}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{args: nil, err: nil},
+		{args: []string{"-file", "example.hcl"}, err: nil},
+		{args: []string{"-h"}, err: flag.ErrHelp},
+		{args: []string{"-help"}, err: flag.ErrHelp},
+		{args: []string{"-bogus"}, err: errUsage},
+		{args: []string{"-file"}, err: errUsage},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		flags.String("file", "", "")
+		if err := parseFlags(flags, test.args); err != test.err {
+			t.Errorf("%q: got %v, want %v", test.args, err, test.err)
+		}
+	}
+}
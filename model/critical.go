@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+)
+
+// Estimate is the expected duration of each phase of a deployment.
+type Estimate struct {
+	// Deploy is the time from starting the deployment until the framework
+	// reports it is complete.
+	Deploy time.Duration
+	// Health is the time from completing the deployment until it becomes
+	// healthy.
+	Health time.Duration
+}
+
+// CriticalStep is a deployment along a critical path, with the times it is
+// expected to start, finish deploying, and become healthy, relative to the
+// start of the whole deployment.
+type CriticalStep struct {
+	Deployment DeploymentRef
+	// WaitForHealthy is true if the step started when the previous step
+	// became healthy rather than when it finished deploying.
+	WaitForHealthy bool
+	Start          time.Duration
+	Deployed       time.Duration
+	Healthy        time.Duration
+}
+
+// CriticalPath is the chain of dependencies which bounds the time to deploy
+// a graph, given unlimited concurrency.
+type CriticalPath struct {
+	// Steps are the deployments along the path, in the order they are
+	// deployed.
+	Steps []CriticalStep
+	// Duration is the theoretical minimum wall-clock time to deploy the
+	// graph, which is when the last step becomes healthy.
+	Duration time.Duration
+}
+
+// CriticalPath computes the critical path of the graph, given estimates for
+// its deployments; deployments without an estimate are expected to take no
+// time.
+// Each deployment is expected to start as soon as each of its dependencies
+// has finished deploying or, if it waits for healthy, has become healthy.
+// Returns a non-nil error if and only if there are cycles in the graph.
+func (g *Graph) CriticalPath(estimates map[DeploymentRef]Estimate) (*CriticalPath, error) {
+	order, err := g.DeployOrder()
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]CriticalStep, len(g.deployments))
+	previous := make([]int, len(g.deployments))
+	last := -1
+	for _, ref := range order {
+		v := g.index[ref]
+		step := &steps[v]
+		step.Deployment = ref
+		previous[v] = -1
+		for _, w := range g.successors(v) {
+			edge := g.edges[edgeKey{v, w}]
+			ready := steps[w].Deployed
+			if edge.WaitForHealthy {
+				ready = steps[w].Healthy
+			}
+			if previous[v] == -1 || ready > step.Start {
+				step.Start = ready
+				step.WaitForHealthy = edge.WaitForHealthy
+				previous[v] = w
+			}
+		}
+		estimate := estimates[ref]
+		step.Deployed = step.Start + estimate.Deploy
+		step.Healthy = step.Deployed + estimate.Health
+		if last == -1 || step.Healthy > steps[last].Healthy {
+			last = v
+		}
+	}
+	path := &CriticalPath{}
+	if last == -1 {
+		return path, nil
+	}
+	path.Duration = steps[last].Healthy
+	for v := last; v != -1; v = previous[v] {
+		path.Steps = append([]CriticalStep{steps[v]}, path.Steps...)
+	}
+	return path, nil
+}
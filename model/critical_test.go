@@ -0,0 +1,103 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCriticalPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     string
+		edges     []string
+		estimates map[string]Estimate
+		steps     string
+		duration  time.Duration
+	}{
+		{
+			name:  "empty",
+			steps: "",
+		},
+		{
+			name:      "single",
+			names:     "a",
+			estimates: map[string]Estimate{"a": {Deploy: 2 * time.Second, Health: 3 * time.Second}},
+			steps:     "a@0s-2s-5s",
+			duration:  5 * time.Second,
+		},
+		{
+			name:  "immediate dependency",
+			names: "a b",
+			edges: []string{"a -> b"},
+			estimates: map[string]Estimate{
+				"a": {Deploy: time.Second, Health: time.Second},
+				"b": {Deploy: 2 * time.Second, Health: 10 * time.Second},
+			},
+			// b is healthy at 12s, long after a is
+			steps:    "b@0s-2s-12s",
+			duration: 12 * time.Second,
+		},
+		{
+			name:  "waiting dependency",
+			names: "a b",
+			edges: []string{"a => b"},
+			estimates: map[string]Estimate{
+				"a": {Deploy: time.Second, Health: time.Second},
+				"b": {Deploy: 2 * time.Second, Health: 10 * time.Second},
+			},
+			steps:    "b@0s-2s-12s, =>a@12s-13s-14s",
+			duration: 14 * time.Second,
+		},
+		{
+			name:  "slowest branch",
+			names: "a b c d",
+			edges: []string{"a => b", "a => c", "b -> d", "c -> d"},
+			estimates: map[string]Estimate{
+				"a": {Deploy: time.Second},
+				"b": {Deploy: time.Second, Health: 5 * time.Second},
+				"c": {Deploy: 3 * time.Second, Health: time.Second},
+				"d": {Deploy: time.Second},
+			},
+			steps:    "d@0s-1s-1s, b@1s-2s-7s, =>a@7s-8s-8s",
+			duration: 8 * time.Second,
+		},
+		{
+			name:  "no estimates",
+			names: "a b",
+			edges: []string{"a -> b"},
+			// ties are broken by deploy order
+			steps:    "b@0s-0s-0s",
+			duration: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			estimates := make(map[DeploymentRef]Estimate, len(test.estimates))
+			for name, estimate := range test.estimates {
+				estimates[testRef(name)] = estimate
+			}
+			path, err := g.CriticalPath(estimates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps := make([]string, len(path.Steps))
+			for i, step := range path.Steps {
+				wait := ""
+				if step.WaitForHealthy {
+					wait = "=>"
+				}
+				steps[i] = fmt.Sprintf("%s%s@%s-%s-%s", wait, step.Deployment.Name, step.Start, step.Deployed,
+					step.Healthy)
+			}
+			if actual := strings.Join(steps, ", "); actual != test.steps {
+				t.Errorf("got steps %q, want %q", actual, test.steps)
+			}
+			if path.Duration != test.duration {
+				t.Errorf("got duration %s, want %s", path.Duration, test.duration)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// rootSchema is the schema of the top level of a configuration file.
+var rootSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "mesos"},
+		{Type: "framework", LabelNames: []string{"type", "name"}},
+		{Type: "deployment", LabelNames: []string{"type", "name"}},
+		{Type: "defaults"},
+	},
+}
+
+// deploymentMetaSchema is the schema of the parts of a deployment block that
+// are not decoded by gohcl: the meta-arguments, which are evaluated before the
+// rest of the block, and the override block, whose attributes are arbitrary.
+var deploymentMetaSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "for_each"},
+		{Name: "count"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "override"},
+	},
+}
+
+// DecodeFile parses the named file with parser and decodes it into root.
+// Files with the extension ".json" are parsed as HCL JSON, all other files
+// are parsed as native HCL syntax.
+// The directory of the file is recorded in root.Dir.
+// Deployment blocks with a for_each or count meta-argument are expanded into
+// one Deployment per instance; refer to DecodeBody for details.
+func DecodeFile(parser *hclparse.Parser, filename string, ctx *hcl.EvalContext, root *Root) hcl.Diagnostics {
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if filepath.Ext(filename) == ".json" {
+		file, diags = parser.ParseJSONFile(filename)
+	} else {
+		file, diags = parser.ParseHCLFile(filename)
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	root.Dir = filepath.Dir(filename)
+	return append(diags, DecodeBody(file.Body, ctx, root)...)
+}
+
+// DecodeBody decodes the top-level body of a configuration file into root.
+//
+// A deployment block may have a for_each meta-argument, whose value is a map,
+// object, or set of strings, or a count meta-argument, whose value is a
+// non-negative whole number, but not both.
+// The block is then decoded once per element, with each.key and each.value
+// or count.index available to its expressions, and each instance is named
+// after its key, e.g. worker["us"] or worker[0].
+func DecodeBody(body hcl.Body, ctx *hcl.EvalContext, root *Root) hcl.Diagnostics {
+	if ctx == nil {
+		ctx = &hcl.EvalContext{}
+	}
+	content, diags := body.Content(rootSchema)
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "mesos":
+			if root.Mesos != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate mesos block",
+					Detail:   "Only one mesos block is allowed.",
+					Subject:  &block.DefRange,
+				})
+				continue
+			}
+			root.Mesos = &Mesos{}
+			diags = append(diags, gohcl.DecodeBody(block.Body, ctx, root.Mesos)...)
+		case "framework":
+			framework := Framework{
+				Type:      block.Labels[0],
+				Name:      block.Labels[1],
+				DeclRange: block.DefRange,
+				TypeRange: block.LabelRanges[0],
+				NameRange: block.LabelRanges[1],
+			}
+			diags = append(diags, gohcl.DecodeBody(block.Body, ctx, &framework)...)
+			framework.CreatedByRange = block.DefRange
+			if createdBy := nestedBlocks(block.Body, "created_by_deployment"); len(createdBy) != 0 {
+				framework.CreatedByRange = createdBy[0].DefRange
+			}
+			root.Frameworks = append(root.Frameworks, framework)
+		case "deployment":
+			deployments, moreDiags := decodeDeploymentBlock(block, ctx)
+			diags = append(diags, moreDiags...)
+			root.Deployments = append(root.Deployments, deployments...)
+		case "defaults":
+			defaults := Defaults{
+				DeclRange: block.DefRange,
+			}
+			diags = append(diags, gohcl.DecodeBody(block.Body, ctx, &defaults)...)
+			defaults.TypeRange = attributeRange(block.Body, "type", block.DefRange)
+			defaults.DefinitionRange = attributeRange(block.Body, "definition", block.DefRange)
+			setFilterRanges(defaults.Filters, block.Body)
+			root.Defaults = append(root.Defaults, defaults)
+		}
+	}
+	return diags
+}
+
+// deploymentInstance holds the name and meta-argument variables of a single
+// instance of a deployment block.
+type deploymentInstance struct {
+	name      string
+	variables map[string]cty.Value
+}
+
+// decodeDeploymentBlock decodes a deployment block into one deployment for
+// each of its instances.
+func decodeDeploymentBlock(block *hcl.Block, ctx *hcl.EvalContext) ([]Deployment, hcl.Diagnostics) {
+	meta, body, diags := block.Body.PartialContent(deploymentMetaSchema)
+	deploymentType, name := block.Labels[0], block.Labels[1]
+	forEach, hasForEach := meta.Attributes["for_each"]
+	count, hasCount := meta.Attributes["count"]
+	var instances []deploymentInstance
+	switch {
+	case hasForEach && hasCount:
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid combination of \"count\" and \"for_each\"",
+			Detail:   "The \"count\" and \"for_each\" meta-arguments are mutually-exclusive, only one should be used.",
+			Subject:  count.NameRange.Ptr(),
+		})
+	case hasForEach:
+		var moreDiags hcl.Diagnostics
+		instances, moreDiags = forEachInstances(name, forEach, ctx)
+		diags = append(diags, moreDiags...)
+	case hasCount:
+		var moreDiags hcl.Diagnostics
+		instances, moreDiags = countInstances(name, count, ctx)
+		diags = append(diags, moreDiags...)
+	default:
+		instances = []deploymentInstance{{name: name}}
+	}
+	var override *hcl.Block
+	for _, block := range meta.Blocks {
+		if override != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate override block",
+				Detail:   "Only one override block is allowed per deployment.",
+				Subject:  &block.DefRange,
+			})
+			continue
+		}
+		override = block
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	deployments := make([]Deployment, len(instances))
+	for i, instance := range instances {
+		instanceCtx := ctx
+		if instance.variables != nil {
+			instanceCtx = ctx.NewChild()
+			instanceCtx.Variables = instance.variables
+		}
+		deployment := &deployments[i]
+		deployment.Type = deploymentType
+		deployment.Name = instance.name
+		diags = append(diags, gohcl.DecodeBody(body, instanceCtx, deployment)...)
+		deployment.DeclRange = block.DefRange
+		deployment.TypeRange = block.LabelRanges[0]
+		deployment.NameRange = block.LabelRanges[1]
+		deployment.FrameworkRange = attributeRange(body, "framework", block.DefRange)
+		deployment.DefinitionRange = attributeRange(body, "definition", block.DefRange)
+		deployment.ExpectedDeployTimeRange = attributeRange(body, "expected_deploy_time", block.DefRange)
+		deployment.ExpectedHealthTimeRange = attributeRange(body, "expected_health_time", block.DefRange)
+		setDependencySpecRanges(deployment.Dependencies, nestedBlocks(body, "dependency"))
+		setDependencySpecRanges(deployment.DependencyOf, nestedBlocks(body, "dependency_of"))
+		if override != nil {
+			var moreDiags hcl.Diagnostics
+			deployment.Override, moreDiags = decodeOverrideBlock(override, instanceCtx)
+			diags = append(diags, moreDiags...)
+		}
+	}
+	return deployments, diags
+}
+
+// forEachInstances evaluates a for_each meta-argument and returns the
+// resulting instances, named and ordered by key.
+func forEachInstances(name string, attr *hcl.Attribute, ctx *hcl.EvalContext) ([]deploymentInstance, hcl.Diagnostics) {
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	invalid := func(detail string) hcl.Diagnostics {
+		return append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid for_each argument",
+			Detail:      detail,
+			Subject:     attr.Expr.Range().Ptr(),
+			Expression:  attr.Expr,
+			EvalContext: ctx,
+		})
+	}
+	ty := value.Type()
+	switch {
+	case value.IsNull():
+		return nil, invalid("The given \"for_each\" argument value is null.")
+	case !value.IsWhollyKnown():
+		return nil, invalid("The \"for_each\" value must be known when the configuration is decoded.")
+	case ty.IsMapType() || ty.IsObjectType():
+		// ok
+	case ty.IsSetType():
+		if !ty.ElementType().Equals(cty.String) {
+			return nil, invalid("The \"for_each\" argument must be a map, object, or set of strings, " +
+				"but a set of " + ty.ElementType().FriendlyName() + " was given.")
+		}
+	default:
+		return nil, invalid("The \"for_each\" argument must be a map, object, or set of strings, " +
+			"but " + ty.FriendlyName() + " was given.")
+	}
+	var instances []deploymentInstance
+	for it := value.ElementIterator(); it.Next(); {
+		key, element := it.Element()
+		if ty.IsSetType() {
+			key = element
+		}
+		if key.IsNull() {
+			return nil, invalid("The \"for_each\" set must not contain null values.")
+		}
+		instances = append(instances, deploymentInstance{
+			name: fmt.Sprintf("%s[%q]", name, key.AsString()),
+			variables: map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{
+					"key":   key,
+					"value": element,
+				}),
+			},
+		})
+	}
+	return instances, diags
+}
+
+// countInstances evaluates a count meta-argument and returns the resulting
+// instances, named and ordered by index.
+func countInstances(name string, attr *hcl.Attribute, ctx *hcl.EvalContext) ([]deploymentInstance, hcl.Diagnostics) {
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	var count int
+	if value.IsNull() || !value.IsWhollyKnown() || gocty.FromCtyValue(value, &count) != nil || count < 0 {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid count argument",
+			Detail:      "The \"count\" argument must be a known, non-negative whole number.",
+			Subject:     attr.Expr.Range().Ptr(),
+			Expression:  attr.Expr,
+			EvalContext: ctx,
+		})
+	}
+	instances := make([]deploymentInstance, count)
+	for i := range instances {
+		instances[i] = deploymentInstance{
+			name: fmt.Sprintf("%s[%d]", name, i),
+			variables: map[string]cty.Value{
+				"count": cty.ObjectVal(map[string]cty.Value{
+					"index": cty.NumberIntVal(int64(i)),
+				}),
+			},
+		}
+	}
+	return instances, diags
+}
+
+// decodeOverrideBlock decodes the attributes of an override block into an
+// object, in the same way as an object constructor expression.
+func decodeOverrideBlock(block *hcl.Block, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	attrs, diags := block.Body.JustAttributes()
+	values := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		value, moreDiags := attr.Expr.Value(ctx)
+		diags = append(diags, moreDiags...)
+		values[name] = value
+	}
+	return cty.ObjectVal(values), diags
+}
+
+// setDependencySpecRanges sets the ranges of specs, which were decoded by
+// gohcl from the given blocks in the same order.
+func setDependencySpecRanges(specs []DependencySpec, blocks hcl.Blocks) {
+	for i := range specs {
+		if i >= len(blocks) {
+			return
+		}
+		spec, block := &specs[i], blocks[i]
+		spec.DeclRange = block.DefRange
+		spec.TypeRange = attributeRange(block.Body, "type", block.DefRange)
+		spec.NameRange = attributeRange(block.Body, "name", block.DefRange)
+		setFilterRanges(spec.Filters, block.Body)
+	}
+}
+
+// setFilterRanges sets the ranges of filters, which were decoded by gohcl from
+// the filter blocks nested in body.
+func setFilterRanges(filters []Filter, body hcl.Body) {
+	blocks := nestedBlocks(body, "filter")
+	for i := range filters {
+		if i >= len(blocks) {
+			return
+		}
+		filter, block := &filters[i], blocks[i]
+		filter.DeclRange = block.DefRange
+		filter.KeyRange = attributeRange(block.Body, "key", block.DefRange)
+	}
+}
+
+// attributeRange returns the range of the expression of the named attribute
+// of body, or fallback if body has no such attribute.
+func attributeRange(body hcl.Body, name string, fallback hcl.Range) hcl.Range {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: name}},
+	})
+	if attr, ok := content.Attributes[name]; ok {
+		return attr.Expr.Range()
+	}
+	return fallback
+}
+
+// nestedBlocks returns the blocks of the given type nested in body, in the
+// order they are declared.
+func nestedBlocks(body hcl.Body, blockType string) hcl.Blocks {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: blockType}},
+	})
+	return content.Blocks
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testDecode parses src as native HCL syntax and decodes it into a Root.
+func testDecode(t *testing.T, src string, ctx *hcl.EvalContext) (*Root, hcl.Diagnostics) {
+	t.Helper()
+	file, diags := hclparse.NewParser().ParseHCL([]byte(src), "test.hcl")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	var root Root
+	return &root, DecodeBody(file.Body, ctx, &root)
+}
+
+func TestDecodeBodyInstances(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"regions": cty.MapVal(map[string]cty.Value{
+				"us": cty.StringVal("us-east"),
+				"eu": cty.StringVal("eu-west"),
+			}),
+			"zones":    cty.SetVal([]cty.Value{cty.StringVal("b"), cty.StringVal("a")}),
+			"ports":    cty.SetVal([]cty.Value{cty.NumberIntVal(80)}),
+			"nothing":  cty.NullVal(cty.Map(cty.String)),
+			"unknown":  cty.UnknownVal(cty.Map(cty.String)),
+			"replicas": cty.NumberIntVal(2),
+			"pending":  cty.UnknownVal(cty.Number),
+		},
+	}
+	tests := []struct {
+		name        string
+		meta        string
+		deployments []string
+		err         string
+	}{
+		{
+			name:        "no meta-arguments",
+			deployments: []string{"worker"},
+		},
+		{
+			name:        "for_each map",
+			meta:        "for_each = regions\nlabels = [each.key, each.value]",
+			deployments: []string{`worker["eu"] eu eu-west`, `worker["us"] us us-east`},
+		},
+		{
+			name:        "for_each object",
+			meta:        "for_each = { us = 1, eu = 2 }\nlabels = [each.key, each.value]",
+			deployments: []string{`worker["eu"] eu 2`, `worker["us"] us 1`},
+		},
+		{
+			name:        "for_each set",
+			meta:        "for_each = zones\nlabels = [each.key, each.value]",
+			deployments: []string{`worker["a"] a a`, `worker["b"] b b`},
+		},
+		{
+			name:        "for_each empty",
+			meta:        "for_each = {}",
+			deployments: nil,
+		},
+		{
+			name:        "count",
+			meta:        "count = replicas\nlabels = [\"replica-${count.index}\"]",
+			deployments: []string{"worker[0] replica-0", "worker[1] replica-1"},
+		},
+		{
+			name:        "count zero",
+			meta:        "count = 0",
+			deployments: nil,
+		},
+		{
+			name: "for_each and count",
+			meta: "for_each = regions\ncount = 2",
+			err:  "Invalid combination of \"count\" and \"for_each\"",
+		},
+		{
+			name: "for_each tuple",
+			meta: "for_each = [\"us\", \"eu\"]",
+			err:  "must be a map, object, or set of strings, but tuple was given",
+		},
+		{
+			name: "for_each set of numbers",
+			meta: "for_each = ports",
+			err:  "must be a map, object, or set of strings, but a set of number was given",
+		},
+		{
+			name: "for_each null",
+			meta: "for_each = nothing",
+			err:  "The given \"for_each\" argument value is null.",
+		},
+		{
+			name: "for_each unknown",
+			meta: "for_each = unknown",
+			err:  "The \"for_each\" value must be known when the configuration is decoded.",
+		},
+		{
+			name: "count negative",
+			meta: "count = -1",
+			err:  "Invalid count argument",
+		},
+		{
+			name: "count fractional",
+			meta: "count = 1.5",
+			err:  "Invalid count argument",
+		},
+		{
+			name: "count unknown",
+			meta: "count = pending",
+			err:  "Invalid count argument",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := "deployment \"marathon_app\" \"worker\" {\n" + test.meta + "\n}\n"
+			root, diags := testDecode(t, src, ctx)
+			if test.err != "" {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), test.err) {
+					t.Fatalf("got diagnostics %v, want %s", diags, test.err)
+				}
+				if len(root.Deployments) != 0 {
+					t.Errorf("got %d deployments, want none", len(root.Deployments))
+				}
+				return
+			} else if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			var actual []string
+			for _, deployment := range root.Deployments {
+				actual = append(actual, strings.Join(append([]string{deployment.Name}, deployment.Labels...), " "))
+				if !IsValidDeploymentName(deployment.Name) {
+					t.Errorf("generated name %s is not a valid deployment name", deployment.Name)
+				}
+				if BaseName(deployment.Name) != "worker" {
+					t.Errorf("got base name %s of %s, want worker", BaseName(deployment.Name), deployment.Name)
+				}
+			}
+			if strings.Join(actual, ", ") != strings.Join(test.deployments, ", ") {
+				t.Errorf("got deployments %q, want %q", actual, test.deployments)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyOverrideInstances(t *testing.T) {
+	root, diags := testDecode(t, `
+deployment "marathon_app" "worker" {
+  count = 2
+  override {
+    instances = count.index + 1
+  }
+}
+`, nil)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if len(root.Deployments) != 2 {
+		t.Fatalf("got %d deployments, want 2", len(root.Deployments))
+	}
+	for i, deployment := range root.Deployments {
+		actual := deployment.Override.GetAttr("instances")
+		if expected := cty.NumberIntVal(int64(i + 1)); !actual.RawEquals(expected) {
+			t.Errorf("%s: got instances %#v, want %#v", deployment.Name, actual, expected)
+		}
+	}
+}
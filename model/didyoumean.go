@@ -0,0 +1,31 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/agext/levenshtein"
+)
+
+// nameSuggestion returns the name from suggestions that is closest to given,
+// if it is close enough to plausibly be what was meant, or else the empty
+// string.
+// Ties are broken in favor of earlier suggestions.
+func nameSuggestion(given string, suggestions []string) string {
+	best, bestDist := "", 3 // threshold as used by HCL itself
+	for _, suggestion := range suggestions {
+		if dist := levenshtein.Distance(given, suggestion, nil); dist < bestDist {
+			best, bestDist = suggestion, dist
+		}
+	}
+	return best
+}
+
+// didYouMean returns a sentence suggesting the closest of suggestions to
+// given, suitable for appending to the detail of a diagnostic, or the empty
+// string if there is no close suggestion.
+func didYouMean(given string, suggestions []string) string {
+	if suggestion := nameSuggestion(given, suggestions); suggestion != "" {
+		return fmt.Sprintf(" Did you mean \"%s\"?", suggestion)
+	}
+	return ""
+}
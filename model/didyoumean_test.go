@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestNameSuggestion(t *testing.T) {
+	tests := []struct {
+		given       string
+		suggestions []string
+		suggestion  string
+	}{
+		{given: "marathn", suggestions: []string{"marathon", "chronos"}, suggestion: "marathon"},
+		{given: "chronos", suggestions: []string{"marathon", "chronos"}, suggestion: "chronos"},
+		{given: "nmae", suggestions: []string{"name", "labels"}, suggestion: "name"},
+		{given: "lables", suggestions: []string{"name", "labels"}, suggestion: "labels"},
+		{given: "marathon_job", suggestions: []string{"marathon_app"}, suggestion: ""},
+		{given: "marathon_ap", suggestions: []string{"marathon_app", "chronos_job"}, suggestion: "marathon_app"},
+		{given: "ab", suggestions: []string{"abcd"}, suggestion: "abcd"},
+		{given: "ab", suggestions: []string{"abcde"}, suggestion: ""},
+		{given: "framework", suggestions: []string{"name", "labels"}, suggestion: ""},
+		{given: "web", suggestions: []string{"wbe", "web_"}, suggestion: "web_"},
+		{given: "web", suggestions: []string{"web1", "web2"}, suggestion: "web1"},
+		{given: "web", suggestions: nil, suggestion: ""},
+	}
+	for _, test := range tests {
+		if actual := nameSuggestion(test.given, test.suggestions); actual != test.suggestion {
+			t.Errorf("%s in %q: got %q, want %q", test.given, test.suggestions, actual, test.suggestion)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	if actual, expected := didYouMean("defualt", []string{"default"}), ` Did you mean "default"?`; actual != expected {
+		t.Errorf("got %q, want %q", actual, expected)
+	}
+	if actual := didYouMean("other", []string{"default"}); actual != "" {
+		t.Errorf("got %q, want no suggestion", actual)
+	}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		input  string
+		filter string
+		err    string
+	}{
+		{input: "labels=monitoring", filter: `labels = "monitoring"`},
+		{input: "labels=a,b", filter: `labels = ["a", "b"]`},
+		{input: "name!=*_cleanup", filter: `name !~ "*_cleanup" (glob)`},
+		{input: "name=web_?", filter: `name ~ "web_?" (glob)`},
+		{input: "name~^web_[0-9]+$", filter: `name ~ "^web_[0-9]+$" (regexp)`},
+		{input: "labels!~^boot", filter: `labels !~ "^boot" (regexp)`},
+		{input: "name=", filter: `name = ""`},
+		{input: "name=worker[0]", filter: `name = "worker[0]"`},
+		{input: `name=web["us"]`, filter: `name = "web[\"us\"]"`},
+		{input: `name=worker\[*\]`, filter: `name ~ "worker\\[*\\]" (glob)`},
+		{
+			input: "labels",
+			err:   "filter \"labels\" must be of the form key=value, key!=value, key~regexp, or key!~regexp",
+		},
+		{
+			input: "=web",
+			err:   "filter \"=web\" must be of the form key=value, key!=value, key~regexp, or key!~regexp",
+		},
+		{
+			input: "name!web",
+			err:   "filter \"name!web\" must be of the form key=value, key!=value, key~regexp, or key!~regexp",
+		},
+		{input: "lables=web", err: "unknown filter key \"lables\", did you mean \"labels\"?"},
+		{input: "framework=web", err: "unknown filter key \"framework\", only \"name\" and \"labels\" supported"},
+		{input: "name~(", err: "invalid regexp"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			filter, err := ParseFilter(test.input)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if actual := filter.String(); actual != test.filter {
+				t.Errorf("got %s, want %s", actual, test.filter)
+			}
+		})
+	}
+}
+
+func TestParseFilterMatches(t *testing.T) {
+	deployments := []Deployment{
+		{Type: "marathon_app", Name: "web_1", Labels: []string{"web"}},
+		{Type: "marathon_app", Name: "web_cleanup", Labels: []string{"web", "batch"}},
+		{Type: "chronos_job", Name: "backup", Labels: []string{"batch"}},
+		{Type: "marathon_app", Name: "worker[0]"},
+		{Type: "marathon_app", Name: "worker[1]"},
+		{Type: "marathon_app", Name: `web["us"]`, Labels: []string{"web"}},
+	}
+	tests := []struct {
+		filter  string
+		matches string
+	}{
+		{filter: "labels=batch", matches: "web_cleanup backup"},
+		{filter: "labels!=batch", matches: `web_1 worker[0] worker[1] web["us"]`},
+		{filter: "name=web_*", matches: "web_1 web_cleanup"},
+		{filter: "name!=*_cleanup", matches: `web_1 backup worker[0] worker[1] web["us"]`},
+		{filter: "name~^web_[0-9]+$", matches: "web_1"},
+		{filter: "labels=web,batch", matches: `web_1 web_cleanup backup web["us"]`},
+		{filter: "name=worker[0]", matches: "worker[0]"},
+		{filter: "name=worker", matches: "worker[0] worker[1]"},
+		{filter: `name=web["us"]`, matches: `web["us"]`},
+		{filter: `name!=web["us"]`, matches: "web_1 web_cleanup backup worker[0] worker[1]"},
+		{filter: `name=worker\[?\]`, matches: "worker[0] worker[1]"},
+	}
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			filter, err := ParseFilter(test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var matches []DeploymentRef
+			for i := range deployments {
+				matched, err := filter.Matches(&deployments[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if matched {
+					matches = append(matches, deployments[i].Ref())
+				}
+			}
+			if actual := formatRefs(matches); actual != test.matches {
+				t.Errorf("got %q, want %q", actual, test.matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Functions returns the functions available to expressions in configuration
+// files and in templates.
+// These are most of the cty standard library, plus file and templatefile.
+// Relative paths given to file and templatefile are resolved against dir,
+// which should be the directory of the configuration file.
+func Functions(dir string) map[string]function.Function {
+	funcs := baseFunctions(dir)
+	funcs["templatefile"] = makeTemplateFileFunc(dir, baseFunctions(dir))
+	return funcs
+}
+
+// ResolvePath returns path if it is absolute, otherwise path relative to dir.
+func ResolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// baseFunctions returns all of the functions returned by Functions except
+// templatefile, which is excluded from templates to prevent recursion.
+func baseFunctions(dir string) map[string]function.Function {
+	return map[string]function.Function{
+		"abs":             stdlib.AbsoluteFunc,
+		"ceil":            stdlib.CeilFunc,
+		"chomp":           stdlib.ChompFunc,
+		"chunklist":       stdlib.ChunklistFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"csvdecode":       stdlib.CSVDecodeFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"file":            makeFileFunc(dir),
+		"flatten":         stdlib.FlattenFunc,
+		"floor":           stdlib.FloorFunc,
+		"format":          stdlib.FormatFunc,
+		"formatdate":      stdlib.FormatDateFunc,
+		"formatlist":      stdlib.FormatListFunc,
+		"indent":          stdlib.IndentFunc,
+		"join":            stdlib.JoinFunc,
+		"jsondecode":      stdlib.JSONDecodeFunc,
+		"jsonencode":      stdlib.JSONEncodeFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          stdlib.LengthFunc,
+		"log":             stdlib.LogFunc,
+		"lookup":          stdlib.LookupFunc,
+		"lower":           stdlib.LowerFunc,
+		"max":             stdlib.MaxFunc,
+		"merge":           stdlib.MergeFunc,
+		"min":             stdlib.MinFunc,
+		"parseint":        stdlib.ParseIntFunc,
+		"pow":             stdlib.PowFunc,
+		"range":           stdlib.RangeFunc,
+		"regex":           stdlib.RegexFunc,
+		"regexall":        stdlib.RegexAllFunc,
+		"regexreplace":    stdlib.RegexReplaceFunc,
+		"replace":         stdlib.ReplaceFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"signum":          stdlib.SignumFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"split":           stdlib.SplitFunc,
+		"strrev":          stdlib.ReverseFunc,
+		"substr":          stdlib.SubstrFunc,
+		"timeadd":         stdlib.TimeAddFunc,
+		"title":           stdlib.TitleFunc,
+		"tobool":          stdlib.MakeToFunc(cty.Bool),
+		"tolist":          stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":           stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber":        stdlib.MakeToFunc(cty.Number),
+		"toset":           stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring":        stdlib.MakeToFunc(cty.String),
+		"trim":            stdlib.TrimFunc,
+		"trimprefix":      stdlib.TrimPrefixFunc,
+		"trimspace":       stdlib.TrimSpaceFunc,
+		"trimsuffix":      stdlib.TrimSuffixFunc,
+		"upper":           stdlib.UpperFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+	}
+}
+
+// makeFileFunc returns a file function, which reads the contents of a file
+// as a string, resolving relative paths against dir.
+func makeFileFunc(dir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			contents, err := ioutil.ReadFile(ResolvePath(dir, args[0].AsString()))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return cty.StringVal(string(contents)), nil
+		},
+	})
+}
+
+// makeTemplateFileFunc returns a templatefile function which renders
+// templates with the given functions, resolving relative paths against dir.
+func makeTemplateFileFunc(dir string, funcs map[string]function.Function) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+			{
+				Name: "vars",
+				Type: cty.DynamicPseudoType,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			result, diags := renderTemplateFile(ResolvePath(dir, args[0].AsString()), args[1], funcs)
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+			return cty.StringVal(result), nil
+		},
+	})
+}
+
+// RenderTemplateFile renders the named file as an HCL template, where vars
+// is a map or object whose elements are the variables of the template.
+// The functions returned by Functions are available in the template, except
+// for templatefile.
+// Relative paths, both filename and those given to file in the template, are
+// resolved against dir.
+func RenderTemplateFile(dir, filename string, vars cty.Value) (string, hcl.Diagnostics) {
+	return renderTemplateFile(ResolvePath(dir, filename), vars, baseFunctions(dir))
+}
+
+// renderTemplateFile is the implementation of RenderTemplateFile and of the
+// templatefile function.
+func renderTemplateFile(filename string, vars cty.Value, funcs map[string]function.Function) (string, hcl.Diagnostics) {
+	invalid := func(summary, detail string) hcl.Diagnostics {
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  summary,
+			Detail:   detail,
+		}}
+	}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", invalid("Failed to read template", fmt.Sprintf("Cannot read \"%s\": %s.", filename, err))
+	}
+	ctx := &hcl.EvalContext{
+		Variables: make(map[string]cty.Value),
+		Functions: funcs,
+	}
+	if !vars.IsNull() {
+		ty := vars.Type()
+		if !ty.IsMapType() && !ty.IsObjectType() {
+			return "", invalid("Invalid template variables",
+				fmt.Sprintf("The variables of template \"%s\" must be a map or object.", filename))
+		}
+		for it := vars.ElementIterator(); it.Next(); {
+			name, value := it.Element()
+			if !IsValidIdentifier(name.AsString()) {
+				return "", invalid("Invalid template variables",
+					fmt.Sprintf("\"%s\" is not a valid template variable name.", name.AsString()))
+			}
+			ctx.Variables[name.AsString()] = value
+		}
+	}
+	expr, diags := hclsyntax.ParseTemplate(src, filename, hcl.Pos{Line: 1, Column: 1, Byte: 0})
+	if diags.HasErrors() {
+		return "", diags
+	}
+	result, moreDiags := expr.Value(ctx)
+	diags = append(diags, moreDiags...)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	result, err = convert.Convert(result, cty.String)
+	if err != nil || result.IsNull() || !result.IsKnown() {
+		return "", append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid template result",
+			Detail:   fmt.Sprintf("Template \"%s\" must produce a string.", filename),
+			Subject:  expr.Range().Ptr(),
+		})
+	}
+	return result.AsString(), diags
+}
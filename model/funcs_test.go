@@ -0,0 +1,163 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testFiles writes files, keyed by slash-separated path, into a new
+// temporary directory and returns its path.
+func testFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFunctionsRelativePaths(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"motd.txt":             "hello",
+		"templates/greet.tmpl": "${greeting}, ${file(\"motd.txt\")}",
+		"templates/bad.tmpl":   "${templatefile(\"greet.tmpl\", {})}",
+	})
+	ctx := &hcl.EvalContext{
+		Functions: Functions(dir),
+	}
+	tests := []struct {
+		expr   string
+		result string
+		err    string
+	}{
+		{expr: `file("motd.txt")`, result: "hello"},
+		{expr: `file("./templates/../motd.txt")`, result: "hello"},
+		{expr: `file("` + filepath.ToSlash(filepath.Join(dir, "motd.txt")) + `")`, result: "hello"},
+		{expr: `templatefile("templates/greet.tmpl", { greeting = "hi" })`, result: "hi, hello"},
+		{expr: `file("missing.txt")`, err: "missing.txt"},
+		{expr: `templatefile("greet.tmpl", {})`, err: "Failed to read template"},
+		{expr: `templatefile("templates/bad.tmpl", {})`, err: "There is no function named \"templatefile\""},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.expr), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			value, diags := expr.Value(ctx)
+			if test.err != "" {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), test.err) {
+					t.Fatalf("got diagnostics %v, want %s", diags, test.err)
+				}
+				return
+			} else if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if actual := value.AsString(); actual != test.result {
+				t.Errorf("got %q, want %q", actual, test.result)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateFile(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"motd.txt":             "hello",
+		"templates/greet.tmpl": "${greeting}, ${file(\"motd.txt\")}",
+		"templates/list.tmpl":  "%{ for name in names }${name};%{ endfor }",
+	})
+	tests := []struct {
+		filename string
+		vars     cty.Value
+		result   string
+		err      string
+	}{
+		{
+			filename: "templates/greet.tmpl",
+			vars:     cty.ObjectVal(map[string]cty.Value{"greeting": cty.StringVal("hi")}),
+			result:   "hi, hello",
+		},
+		{
+			filename: filepath.Join(dir, "templates", "greet.tmpl"),
+			vars:     cty.MapVal(map[string]cty.Value{"greeting": cty.StringVal("hey")}),
+			result:   "hey, hello",
+		},
+		{
+			filename: "templates/list.tmpl",
+			vars: cty.ObjectVal(map[string]cty.Value{
+				"names": cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			}),
+			result: "a;b;",
+		},
+		{
+			filename: "templates/greet.tmpl",
+			vars:     cty.ObjectVal(map[string]cty.Value{}),
+			err:      "Unknown variable",
+		},
+		{
+			filename: "templates/greet.tmpl",
+			vars:     cty.StringVal("hi"),
+			err:      "must be a map or object",
+		},
+		{
+			filename: "templates/greet.tmpl",
+			vars:     cty.ObjectVal(map[string]cty.Value{"greeting": cty.StringVal("hi"), "not-valid": cty.True}),
+			err:      "\"not-valid\" is not a valid template variable name",
+		},
+		{
+			filename: "greet.tmpl",
+			vars:     cty.NullVal(cty.DynamicPseudoType),
+			err:      "Failed to read template",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			result, diags := RenderTemplateFile(dir, test.filename, test.vars)
+			if test.err != "" {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), test.err) {
+					t.Fatalf("got diagnostics %v, want %s", diags, test.err)
+				}
+				return
+			} else if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if result != test.result {
+				t.Errorf("got %q, want %q", result, test.result)
+			}
+		})
+	}
+}
+
+func TestDecodeFileDir(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"conf/main.hcl": `deployment "marathon_app" "web" { deploy = "web.json" }`,
+	})
+	filename := filepath.Join(dir, "conf", "main.hcl")
+	var root Root
+	if diags := DecodeFile(hclparse.NewParser(), filename, nil, &root); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if expected := filepath.Join(dir, "conf"); root.Dir != expected {
+		t.Errorf("got dir %s, want %s", root.Dir, expected)
+	}
+}
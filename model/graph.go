@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -17,6 +18,55 @@ type Graph struct {
 	deployments []DeploymentRef
 	index       map[DeploymentRef]int
 	rawGraph    *graph.Mutable
+	edges       map[edgeKey]*Edge
+}
+
+// edgeKey identifies an edge from one deployment index to another.
+type edgeKey struct {
+	from, to int
+}
+
+// Edge is a resolved dependency of one deployment on another, along with
+// the sources that produced it.
+// If more than one source produced the edge, the edge waits for its target
+// to become healthy if any of them do, and likewise for restarting its origin
+// when its target changes.
+type Edge struct {
+	From            DeploymentRef
+	To              DeploymentRef
+	WaitForHealthy  bool
+	RestartOnChange bool
+	Sources         []EdgeSource
+}
+
+// EdgeSource describes a dependency or dependency_of block which produced an
+// edge.
+type EdgeSource struct {
+	// DeclaredBy is the deployment whose block produced the edge.
+	DeclaredBy DeploymentRef
+	// Inverse is true if the block is a dependency_of block, in which case
+	// DeclaredBy is the target of the edge rather than its origin.
+	Inverse bool
+	// Spec is the block itself, including its range and filters.
+	Spec DependencySpec
+}
+
+// String describes s in a form suitable for messages, such as
+// `dependency_of block of marathon_app.dns (type = "*", labels != "bootstrap")`.
+func (s *EdgeSource) String() string {
+	kind := "dependency"
+	if s.Inverse {
+		kind = "dependency_of"
+	}
+	criteria := []string{fmt.Sprintf("type = \"%s\"", s.Spec.Type)}
+	if s.Spec.Name != "" {
+		criteria = append(criteria, fmt.Sprintf("name = \"%s\"", s.Spec.Name))
+	}
+	for i := range s.Spec.Filters {
+		criteria = append(criteria, s.Spec.Filters[i].String())
+	}
+	return fmt.Sprintf("%s block of %s.%s (%s)", kind, s.DeclaredBy.Type, s.DeclaredBy.Name,
+		strings.Join(criteria, ", "))
 }
 
 // Build builds a graph from the given deployments.
@@ -37,6 +87,7 @@ func (g *Graph) Build(deployments ...Deployment) error {
 		g.index[ref] = i
 	}
 	g.rawGraph = graph.New(len(deployments))
+	g.edges = make(map[edgeKey]*Edge)
 	for i, _ := range deployments {
 		deployment := &deployments[i]
 		for j := range deployment.Dependencies {
@@ -46,12 +97,11 @@ func (g *Graph) Build(deployments ...Deployment) error {
 				return fmt.Errorf("finding dependents of deployment.%s.%s: %w",
 					deployment.Type, deployment.Name, err)
 			}
-			var c int64
-			if dependency.WaitForHealthy {
-				c = 1
-			}
 			for _, k := range dependents {
-				g.rawGraph.AddCost(i, k, c)
+				g.addEdge(i, k, EdgeSource{
+					DeclaredBy: deployment.Ref(),
+					Spec:       *dependency,
+				})
 			}
 		}
 		for j := range deployment.DependencyOf {
@@ -61,52 +111,654 @@ func (g *Graph) Build(deployments ...Deployment) error {
 				return fmt.Errorf("finding inverse dependents of deployment %s.%s: %w",
 					deployment.Type, deployment.Name, err)
 			}
-			var c int64
-			if inverseDependency.WaitForHealthy {
-				c = 1
-			}
 			for _, k := range inverseDependents {
-				g.rawGraph.AddCost(k, i, c)
+				g.addEdge(k, i, EdgeSource{
+					DeclaredBy: deployment.Ref(),
+					Inverse:    true,
+					Spec:       *inverseDependency,
+				})
 			}
 		}
 	}
 	return nil
 }
 
-// Cycles returns a list of all dependency cycles in the graph.
-func (g *Graph) Cycles() [][]DeploymentRef {
-	components := graph.StrongComponents(g.rawGraph)
-	var cycles [][]DeploymentRef
-	for _, component := range components {
-		if len(component) != 1 {
-			cycle := make([]DeploymentRef, len(component))
-			for i, j := range component {
-				cycle[i] = g.deployments[j]
+// BuildFromEdges builds a graph from the given deployments and edges, such as
+// those of another graph, keeping the sources of the edges as they are.
+// Returns a non-nil error if the graph has already been built, or if an edge
+// connects a deployment which is not given or is a duplicate of another.
+func (g *Graph) BuildFromEdges(deployments []DeploymentRef, edges []Edge) error {
+	if len(g.deployments) != 0 {
+		return fmt.Errorf("graph has already been built")
+	}
+	g.deployments = append([]DeploymentRef(nil), deployments...)
+	g.index = make(map[DeploymentRef]int, len(deployments))
+	for i, ref := range deployments {
+		g.index[ref] = i
+	}
+	g.rawGraph = graph.New(len(deployments))
+	g.edges = make(map[edgeKey]*Edge, len(edges))
+	for i := range edges {
+		edge := edges[i]
+		v, ok := g.index[edge.From]
+		if !ok {
+			return fmt.Errorf("edge from unknown deployment %s.%s", edge.From.Type, edge.From.Name)
+		}
+		w, ok := g.index[edge.To]
+		if !ok {
+			return fmt.Errorf("edge to unknown deployment %s.%s", edge.To.Type, edge.To.Name)
+		}
+		key := edgeKey{v, w}
+		if _, exists := g.edges[key]; exists {
+			return fmt.Errorf("duplicate edge from %s.%s to %s.%s", edge.From.Type, edge.From.Name,
+				edge.To.Type, edge.To.Name)
+		}
+		g.edges[key] = &edge
+		var c int64
+		if edge.WaitForHealthy {
+			c = 1
+		}
+		g.rawGraph.AddCost(v, w, c)
+	}
+	return nil
+}
+
+// addEdge adds an edge from deployment index v to w, or adds source to the
+// existing edge, which then waits for healthy if either it or source does.
+func (g *Graph) addEdge(v, w int, source EdgeSource) {
+	key := edgeKey{v, w}
+	edge, exists := g.edges[key]
+	if !exists {
+		edge = &Edge{
+			From: g.deployments[v],
+			To:   g.deployments[w],
+		}
+		g.edges[key] = edge
+	}
+	edge.Sources = append(edge.Sources, source)
+	edge.WaitForHealthy = edge.WaitForHealthy || source.Spec.WaitForHealthy
+	edge.RestartOnChange = edge.RestartOnChange || source.Spec.RestartOnChange
+	var c int64
+	if edge.WaitForHealthy {
+		c = 1
+	}
+	g.rawGraph.AddCost(v, w, c)
+}
+
+// Edges returns all edges of the graph with their sources, ordered by the
+// declaration order of their origins and then of their targets.
+func (g *Graph) Edges() []Edge {
+	keys := make([]edgeKey, 0, len(g.edges))
+	for key := range g.edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return edgeKeyLess(keys[i], keys[j])
+	})
+	edges := make([]Edge, len(keys))
+	for i, key := range keys {
+		edges[i] = *g.edges[key]
+	}
+	return edges
+}
+
+// Dependents returns the edges of all deployments which depend directly on a
+// deployment, ordered by the declaration order of their origins.
+// Returns a non-nil error if and only if the deployment is not in the graph.
+func (g *Graph) Dependents(deployment DeploymentRef) ([]Edge, error) {
+	w, ok := g.index[deployment]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not in graph", deployment.Type, deployment.Name)
+	}
+	var keys []edgeKey
+	for key := range g.edges {
+		if key.to == w {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return edgeKeyLess(keys[i], keys[j])
+	})
+	edges := make([]Edge, len(keys))
+	for i, key := range keys {
+		edges[i] = *g.edges[key]
+	}
+	return edges, nil
+}
+
+// Edge returns the edge from one deployment to another, if there is one.
+func (g *Graph) Edge(from, to DeploymentRef) (Edge, bool) {
+	v, ok := g.index[from]
+	if !ok {
+		return Edge{}, false
+	}
+	w, ok := g.index[to]
+	if !ok {
+		return Edge{}, false
+	}
+	edge, ok := g.edges[edgeKey{v, w}]
+	if !ok {
+		return Edge{}, false
+	}
+	return *edge, true
+}
+
+// ShortestPath returns the edges along a shortest dependency path from one
+// deployment to another, or nil if from does not depend on to, directly or
+// indirectly.
+// Among paths of equal length, the one through the earliest declared
+// deployments is chosen.
+// If from and to are the same deployment, the path is its shortest cycle,
+// which is its edge to itself if it has one.
+// Returns a non-nil error if either deployment is not in the graph.
+func (g *Graph) ShortestPath(from, to DeploymentRef) ([]Edge, error) {
+	v, ok := g.index[from]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not in graph", from.Type, from.Name)
+	}
+	w, ok := g.index[to]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not in graph", to.Type, to.Name)
+	}
+	if v == w {
+		return g.shortestCycle(v), nil
+	}
+	return g.shortestPath(v, w), nil
+}
+
+// shortestCycle returns the edges along a shortest cycle through the
+// deployment with index v, or nil if there is none.
+func (g *Graph) shortestCycle(v int) []Edge {
+	var shortest []Edge
+	for _, x := range g.successors(v) {
+		edge := *g.edges[edgeKey{v, x}]
+		if x == v {
+			return []Edge{edge}
+		}
+		path := g.shortestPath(x, v)
+		if path != nil && (shortest == nil || len(path)+1 < len(shortest)) {
+			shortest = append([]Edge{edge}, path...)
+		}
+	}
+	return shortest
+}
+
+// shortestPath returns the edges along a shortest path from the deployment
+// with index v to the different deployment with index w, or nil if there is
+// none.
+func (g *Graph) shortestPath(v, w int) []Edge {
+	// breadth-first search, visiting dependencies in declaration order
+	parent := map[int]int{v: -1}
+	queue := []int{v}
+	for len(queue) != 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, x := range g.successors(u) {
+			if _, seen := parent[x]; seen {
+				continue
 			}
-			cycles = append(cycles, cycle)
+			parent[x] = u
+			queue = append(queue, x)
 		}
+		if _, found := parent[w]; found {
+			break
+		}
+	}
+	if _, found := parent[w]; !found {
+		return nil
+	}
+	var path []Edge
+	for x := w; parent[x] != -1; x = parent[x] {
+		path = append([]Edge{*g.edges[edgeKey{parent[x], x}]}, path...)
+	}
+	return path
+}
+
+// successors returns the indices of the direct dependencies of the
+// deployment with index v, in declaration order.
+func (g *Graph) successors(v int) []int {
+	var result []int
+	g.rawGraph.Visit(v, func(w int, c int64) bool {
+		result = append(result, w)
+		return false
+	})
+	sort.Ints(result)
+	return result
+}
+
+// maxCycles is the maximum number of cycles returned by Graph.Cycles, since
+// a densely connected graph can have exponentially many.
+const maxCycles = 100
+
+// Cycle is an elementary dependency cycle, given as the edges along it.
+// The target of each edge is the origin of the next, and the target of the
+// last edge is the origin of the first.
+type Cycle []Edge
+
+// String describes c as a path, such as
+// `marathon_app.a -> marathon_app.b -> marathon_app.a`.
+func (c Cycle) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	var result strings.Builder
+	for _, edge := range c {
+		fmt.Fprintf(&result, "%s.%s -> ", edge.From.Type, edge.From.Name)
+	}
+	fmt.Fprintf(&result, "%s.%s", c[0].From.Type, c[0].From.Name)
+	return result.String()
+}
+
+// WithDependencies returns the given deployments along with all of their
+// direct and indirect dependencies, in declaration order.
+// Returns a non-nil error if any of the deployments is not in the graph.
+func (g *Graph) WithDependencies(deployments []DeploymentRef) ([]DeploymentRef, error) {
+	seen := make([]bool, len(g.deployments))
+	var stack []int
+	for _, ref := range deployments {
+		v, ok := g.index[ref]
+		if !ok {
+			return nil, fmt.Errorf("deployment %s.%s not in graph", ref.Type, ref.Name)
+		}
+		if !seen[v] {
+			seen[v] = true
+			stack = append(stack, v)
+		}
+	}
+	for len(stack) != 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, w := range g.successors(v) {
+			if !seen[w] {
+				seen[w] = true
+				stack = append(stack, w)
+			}
+		}
+	}
+	var result []DeploymentRef
+	for v, ref := range g.deployments {
+		if seen[v] {
+			result = append(result, ref)
+		}
+	}
+	return result, nil
+}
+
+// WithDependents returns the given deployments along with all of the
+// deployments which depend on them, directly or indirectly, in declaration
+// order.
+// Returns a non-nil error if any of the deployments is not in the graph.
+func (g *Graph) WithDependents(deployments []DeploymentRef) ([]DeploymentRef, error) {
+	predecessors := make([][]int, len(g.deployments))
+	for key := range g.edges {
+		predecessors[key.to] = append(predecessors[key.to], key.from)
+	}
+	seen := make([]bool, len(g.deployments))
+	var stack []int
+	for _, ref := range deployments {
+		v, ok := g.index[ref]
+		if !ok {
+			return nil, fmt.Errorf("deployment %s.%s not in graph", ref.Type, ref.Name)
+		}
+		if !seen[v] {
+			seen[v] = true
+			stack = append(stack, v)
+		}
+	}
+	for len(stack) != 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, v := range predecessors[w] {
+			if !seen[v] {
+				seen[v] = true
+				stack = append(stack, v)
+			}
+		}
+	}
+	var result []DeploymentRef
+	for v, ref := range g.deployments {
+		if seen[v] {
+			result = append(result, ref)
+		}
+	}
+	return result, nil
+}
+
+// Subgraph returns the graph of the given deployments and the edges between
+// them, leaving out all other deployments and edges.
+// Returns a non-nil error if any of the deployments is not in the graph.
+func (g *Graph) Subgraph(deployments []DeploymentRef) (*Graph, error) {
+	included := make([]bool, len(g.deployments))
+	for _, ref := range deployments {
+		v, ok := g.index[ref]
+		if !ok {
+			return nil, fmt.Errorf("deployment %s.%s not in graph", ref.Type, ref.Name)
+		}
+		included[v] = true
+	}
+	sub := &Graph{
+		index: make(map[DeploymentRef]int),
+		edges: make(map[edgeKey]*Edge),
+	}
+	newIndex := make([]int, len(g.deployments))
+	for v, ref := range g.deployments {
+		if included[v] {
+			newIndex[v] = len(sub.deployments)
+			sub.index[ref] = len(sub.deployments)
+			sub.deployments = append(sub.deployments, ref)
+		}
+	}
+	sub.rawGraph = graph.New(len(sub.deployments))
+	for key, edge := range g.edges {
+		if !included[key.from] || !included[key.to] {
+			continue
+		}
+		subKey := edgeKey{newIndex[key.from], newIndex[key.to]}
+		sub.edges[subKey] = edge
+		var c int64
+		if edge.WaitForHealthy {
+			c = 1
+		}
+		sub.rawGraph.AddCost(subKey.from, subKey.to, c)
+	}
+	return sub, nil
+}
+
+// WaitingFrom returns a copy of the graph in which every dependency of the
+// given deployments waits for its target to become healthy, as it does when
+// they are restarted.
+// Returns a non-nil error if any of the deployments is not in the graph.
+func (g *Graph) WaitingFrom(deployments []DeploymentRef) (*Graph, error) {
+	waiting := make([]bool, len(g.deployments))
+	for _, ref := range deployments {
+		v, ok := g.index[ref]
+		if !ok {
+			return nil, fmt.Errorf("deployment %s.%s not in graph", ref.Type, ref.Name)
+		}
+		waiting[v] = true
+	}
+	result := &Graph{
+		deployments: g.deployments,
+		index:       g.index,
+		rawGraph:    graph.New(len(g.deployments)),
+		edges:       make(map[edgeKey]*Edge, len(g.edges)),
+	}
+	for key, edge := range g.edges {
+		if waiting[key.from] && !edge.WaitForHealthy {
+			copied := *edge
+			copied.WaitForHealthy = true
+			edge = &copied
+		}
+		result.edges[key] = edge
+		var c int64
+		if edge.WaitForHealthy {
+			c = 1
+		}
+		result.rawGraph.AddCost(key.from, key.to, c)
+	}
+	return result, nil
+}
+
+// Cycles returns the elementary dependency cycles in the graph, including
+// deployments which depend on themselves, up to a limit of 100 cycles.
+// Each cycle starts from its earliest declared deployment.
+// The graph is a DAG if and only if no cycles are returned.
+func (g *Graph) Cycles() []Cycle {
+	return g.cycles(nil, maxCycles)
+}
+
+// CycleBreakingEdges returns a set of edges whose removal would leave the
+// graph without cycles, in declaration order.
+// The set is minimal, in that no edge can be left out of it, but there may
+// be smaller sets.
+func (g *Graph) CycleBreakingEdges() []Edge {
+	removed := make(map[edgeKey]bool)
+	var order []edgeKey
+	// greedily remove the edge shared by the most cycles until none remain
+	for {
+		cycles := g.cycles(removed, maxCycles)
+		if len(cycles) == 0 {
+			break
+		}
+		counts := make(map[edgeKey]int)
+		var best edgeKey
+		for _, cycle := range cycles {
+			for _, edge := range cycle {
+				key := edgeKey{g.index[edge.From], g.index[edge.To]}
+				counts[key]++
+				if counts[key] > counts[best] || counts[key] == counts[best] && edgeKeyLess(key, best) {
+					best = key
+				}
+			}
+		}
+		removed[best] = true
+		order = append(order, best)
+	}
+	// restore any edge that is not needed to break the remaining cycles
+	for i := len(order) - 1; i >= 0; i-- {
+		delete(removed, order[i])
+		if len(g.cycles(removed, 1)) != 0 {
+			removed[order[i]] = true
+		}
+	}
+	keys := make([]edgeKey, 0, len(removed))
+	for key := range removed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return edgeKeyLess(keys[i], keys[j])
+	})
+	edges := make([]Edge, len(keys))
+	for i, key := range keys {
+		edges[i] = *g.edges[key]
+	}
+	return edges
+}
+
+// edgeKeyLess orders edge keys by origin and then by target.
+func edgeKeyLess(a, b edgeKey) bool {
+	if a.from != b.from {
+		return a.from < b.from
+	}
+	return a.to < b.to
+}
+
+// cycles finds up to limit elementary cycles in the graph, ignoring the
+// edges in removed, using Johnson's algorithm.
+func (g *Graph) cycles(removed map[edgeKey]bool, limit int) []Cycle {
+	var cycles []Cycle
+	n := len(g.deployments)
+	blocked := make([]bool, n)
+	blockedBy := make([]map[int]bool, n)
+	var stack []int
+	var unblock func(u int)
+	unblock = func(u int) {
+		blocked[u] = false
+		for w := range blockedBy[u] {
+			delete(blockedBy[u], w)
+			if blocked[w] {
+				unblock(w)
+			}
+		}
+	}
+	for s := 0; s < n && len(cycles) < limit; s++ {
+		// only consider cycles whose earliest deployment is s
+		successors := func(v int) []int {
+			var result []int
+			for _, w := range g.successors(v) {
+				if w >= s && !removed[edgeKey{v, w}] {
+					result = append(result, w)
+				}
+			}
+			return result
+		}
+		for v := s; v < n; v++ {
+			blocked[v] = false
+			blockedBy[v] = make(map[int]bool)
+		}
+		var circuit func(v int) bool
+		circuit = func(v int) bool {
+			found := false
+			stack = append(stack, v)
+			blocked[v] = true
+			for _, w := range successors(v) {
+				if len(cycles) >= limit {
+					break
+				}
+				if w == s {
+					cycle := make(Cycle, len(stack))
+					for i, u := range stack {
+						next := s
+						if i+1 < len(stack) {
+							next = stack[i+1]
+						}
+						cycle[i] = *g.edges[edgeKey{u, next}]
+					}
+					cycles = append(cycles, cycle)
+					found = true
+				} else if !blocked[w] && circuit(w) {
+					found = true
+				}
+			}
+			if found {
+				unblock(v)
+			} else {
+				for _, w := range successors(v) {
+					blockedBy[w][v] = true
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return found
+		}
+		circuit(s)
 	}
 	return cycles
 }
 
+// TransitiveReduction returns a copy of the graph without the edges which are
+// implied by longer dependency paths.
+// An edge which does not wait for its target to become healthy is implied by
+// any path to its target, but an edge which does wait is only implied by a
+// path of edges which all wait.
+// Returns a non-nil error if and only if there are cycles in the graph.
+func (g *Graph) TransitiveReduction() (*Graph, error) {
+	if len(g.cycles(nil, 1)) != 0 {
+		return nil, fmt.Errorf("dependency cycles exist")
+	}
+	reduced := &Graph{
+		deployments: g.deployments,
+		index:       g.index,
+		rawGraph:    graph.New(len(g.deployments)),
+		edges:       make(map[edgeKey]*Edge),
+	}
+	for key, edge := range g.edges {
+		if g.implied(key, edge.WaitForHealthy) {
+			continue
+		}
+		reduced.edges[key] = edge
+		var c int64
+		if edge.WaitForHealthy {
+			c = 1
+		}
+		reduced.rawGraph.AddCost(key.from, key.to, c)
+	}
+	return reduced, nil
+}
+
+// implied returns true if there is a path of length two or more along the
+// edge given by key, using only edges that wait for healthy if waitOnly is
+// true.
+func (g *Graph) implied(key edgeKey, waitOnly bool) bool {
+	seen := map[int]bool{key.from: true}
+	var stack []int
+	for _, v := range g.successors(key.from) {
+		if v != key.to && (!waitOnly || g.edges[edgeKey{key.from, v}].WaitForHealthy) {
+			seen[v] = true
+			stack = append(stack, v)
+		}
+	}
+	for len(stack) != 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, w := range g.successors(v) {
+			if seen[w] || waitOnly && !g.edges[edgeKey{v, w}].WaitForHealthy {
+				continue
+			}
+			if w == key.to {
+				return true
+			}
+			seen[w] = true
+			stack = append(stack, w)
+		}
+	}
+	return false
+}
+
 // DeployOrder returns a list of all deployments in the graph, sorted in the
 // order they would be deployed.
-// This is equivalent to a reverse topological sort of the dependency graph.
+// This is the concatenation of the waves returned by Waves, so it is a
+// reverse topological sort of the dependency graph which depends only on the
+// deployments and their dependencies, not on the order they were declared.
 // Returns a non-nil error if and only if there are cycles in the graph.
 func (g *Graph) DeployOrder() ([]DeploymentRef, error) {
-	topSort, ok := graph.TopSort(g.rawGraph)
-	if !ok {
-		return nil, fmt.Errorf("dependency cycles exist")
+	waves, err := g.Waves()
+	if err != nil {
+		return nil, err
 	}
-	deployments := make([]DeploymentRef, len(g.deployments))
-	for i := range topSort {
-		j := topSort[len(topSort)-1-i]
-		deployments[i] = g.deployments[j]
+	deployments := make([]DeploymentRef, 0, len(g.deployments))
+	for _, wave := range waves {
+		deployments = append(deployments, wave...)
 	}
 	return deployments, nil
 }
 
-// Dependencies returns a list of all resolved dependencies for a deployment.
+// Waves groups all deployments in the graph into levels which can be
+// deployed in parallel, each after the ones before it.
+// The first wave holds the deployments with no dependencies, and every other
+// deployment is in the wave after the latest of its dependencies.
+// Deployments within a wave are sorted by type and then by name.
+// Returns a non-nil error if and only if there are cycles in the graph.
+func (g *Graph) Waves() ([][]DeploymentRef, error) {
+	if len(g.cycles(nil, 1)) != 0 {
+		return nil, fmt.Errorf("dependency cycles exist")
+	}
+	levels := make([]int, len(g.deployments))
+	for i := range levels {
+		levels[i] = -1
+	}
+	var level func(v int) int
+	level = func(v int) int {
+		if levels[v] < 0 {
+			levels[v] = 0
+			for _, w := range g.successors(v) {
+				if l := level(w) + 1; l > levels[v] {
+					levels[v] = l
+				}
+			}
+		}
+		return levels[v]
+	}
+	var waves [][]DeploymentRef
+	for v, ref := range g.deployments {
+		l := level(v)
+		for len(waves) <= l {
+			waves = append(waves, nil)
+		}
+		waves[l] = append(waves[l], ref)
+	}
+	for _, wave := range waves {
+		sort.Slice(wave, func(i, j int) bool {
+			if wave[i].Type != wave[j].Type {
+				return wave[i].Type < wave[j].Type
+			}
+			return wave[i].Name < wave[j].Name
+		})
+	}
+	return waves, nil
+}
+
+// Dependencies returns a list of all resolved dependencies for a deployment,
+// sorted by type and then by name.
 // Returns a non-nil error if and only if the deployment is not in the graph.
 func (g *Graph) Dependencies(deployment DeploymentRef) ([]DependencyRef, error) {
 	v, ok := g.index[deployment]
@@ -124,9 +776,22 @@ func (g *Graph) Dependencies(deployment DeploymentRef) ([]DependencyRef, error)
 		})
 		return false
 	})
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i].Type != dependencies[j].Type {
+			return dependencies[i].Type < dependencies[j].Type
+		}
+		return dependencies[i].Name < dependencies[j].Name
+	})
 	return dependencies, nil
 }
 
+// Targets returns the indices of all the deployments that match s.
+// Returns a non-nil error if s is invalid or if s has a name that does not
+// match any deployment.
+func (s *DependencySpec) Targets(deployments []Deployment) ([]int, error) {
+	return findDependents(s, deployments)
+}
+
 // findDependents returns a slice of all the deployment indices that match
 // the given dependency spec.
 func findDependents(dependency *DependencySpec, deployments []Deployment) ([]int, error) {
@@ -174,7 +839,7 @@ func findDependents(dependency *DependencySpec, deployments []Deployment) ([]int
 			dependents = append(dependents, i)
 		}
 	}
-	if dependency.Name != "" && len(dependents) != 1 {
+	if dependency.Name != "" && len(dependents) == 0 {
 		return nil, fmt.Errorf("dependent deployment %s.%s not found", dependency.Type, dependency.Name)
 	}
 	return dependents, nil
@@ -183,17 +848,17 @@ func findDependents(dependency *DependencySpec, deployments []Deployment) ([]int
 // filterMatches returns true if and only if the given filter matches the
 // given deployment.
 func filterMatches(filter *Filter, deployment *Deployment) (bool, error) {
-	values := filter.Values
+	values := filter.values()
 	if len(values) == 0 {
-		if filter.Value == "" {
-			return false, fmt.Errorf("filter must have one of value or values attribute")
-		}
-		values = []string{filter.Value}
+		return false, fmt.Errorf("filter must have one of value or values attribute")
 	}
 	var compareTo []string
 	switch filter.Key {
 	case "name":
 		compareTo = []string{deployment.Name}
+		if baseName := BaseName(deployment.Name); baseName != deployment.Name {
+			compareTo = append(compareTo, baseName)
+		}
 	case "labels":
 		compareTo = deployment.Labels
 	default:
@@ -204,17 +869,9 @@ func filterMatches(filter *Filter, deployment *Deployment) (bool, error) {
 	}
 	for _, val := range values {
 		if filter.Glob || filter.Regexp {
-			valRegexp := val
-			if filter.Glob {
-				var err error
-				valRegexp, err = globToRegexp(val)
-				if err != nil {
-					return false, fmt.Errorf("invalid glob pattern \"%s\": %w", val, err)
-				}
-			}
-			compiled, err := regexp.Compile(valRegexp)
+			compiled, err := filterPattern(filter, val)
 			if err != nil {
-				return false, fmt.Errorf("invalid regexp pattern \"%s\": %w", valRegexp, err)
+				return false, err
 			}
 			for _, cmp := range compareTo {
 				if compiled.MatchString(cmp) {
@@ -232,6 +889,110 @@ func filterMatches(filter *Filter, deployment *Deployment) (bool, error) {
 	return filter.Negate, nil
 }
 
+// String describes f in a form suitable for messages, such as
+// `labels != ["bootstrap", "monitoring"]` or `name ~ "web_*" (glob)`.
+func (f Filter) String() string {
+	operator := "="
+	if f.Glob || f.Regexp {
+		operator = "~"
+	}
+	if f.Negate {
+		operator = "!" + operator
+	}
+	values := f.values()
+	var value string
+	if len(values) == 1 {
+		value = fmt.Sprintf("%q", values[0])
+	} else {
+		quoted := make([]string, len(values))
+		for i, val := range values {
+			quoted[i] = fmt.Sprintf("%q", val)
+		}
+		value = "[" + strings.Join(quoted, ", ") + "]"
+	}
+	switch {
+	case f.Glob:
+		value += " (glob)"
+	case f.Regexp:
+		value += " (regexp)"
+	}
+	return fmt.Sprintf("%s %s %s", f.Key, operator, value)
+}
+
+// ParseFilter parses a filter given on the command line, such as
+// `labels=monitoring` or `name!=*_cleanup`, which is the inverse of String
+// except that glob filters use "=" and values are separated by commas.
+// The operators are "=" and "!=", which compare values as globs if they
+// contain "*" or "?", and "~" and "!~", which compare values as regular
+// expressions.
+// Brackets alone do not make a glob, so that instance names such as
+// worker[0] can be matched exactly.
+// Returns a non-nil error if s is not a valid filter.
+func ParseFilter(s string) (Filter, error) {
+	i := strings.IndexAny(s, "=~!")
+	if i <= 0 {
+		return Filter{}, fmt.Errorf("filter \"%s\" must be of the form key=value, key!=value, key~regexp, "+
+			"or key!~regexp", s)
+	}
+	filter := Filter{Key: s[:i]}
+	rest := s[i:]
+	if strings.HasPrefix(rest, "!") {
+		filter.Negate = true
+		rest = rest[1:]
+	}
+	switch {
+	case strings.HasPrefix(rest, "="):
+		filter.Glob = strings.ContainsAny(rest, "*?")
+	case strings.HasPrefix(rest, "~"):
+		filter.Regexp = true
+	default:
+		return Filter{}, fmt.Errorf("filter \"%s\" must be of the form key=value, key!=value, key~regexp, "+
+			"or key!~regexp", s)
+	}
+	filter.Values = strings.Split(rest[1:], ",")
+	if filter.Key != "name" && filter.Key != "labels" {
+		if suggestion := nameSuggestion(filter.Key, []string{"name", "labels"}); suggestion != "" {
+			return Filter{}, fmt.Errorf("unknown filter key \"%s\", did you mean \"%s\"?", filter.Key, suggestion)
+		}
+		return Filter{}, fmt.Errorf("unknown filter key \"%s\", only \"name\" and \"labels\" supported",
+			filter.Key)
+	}
+	if filter.Glob || filter.Regexp {
+		for _, val := range filter.Values {
+			if _, err := filterPattern(&filter, val); err != nil {
+				return Filter{}, err
+			}
+		}
+	}
+	return filter, nil
+}
+
+// values returns the values of filter, whether given by its value or values
+// attribute.
+func (f *Filter) values() []string {
+	if len(f.Values) == 0 && f.Value != "" {
+		return []string{f.Value}
+	}
+	return f.Values
+}
+
+// filterPattern compiles a value of a glob or regexp filter.
+func filterPattern(filter *Filter, val string) (*regexp.Regexp, error) {
+	valRegexp := val
+	if filter.Glob {
+		var err error
+		valRegexp, err = globToRegexp(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern \"%s\": %w", val, err)
+		}
+	}
+	compiled, err := regexp.Compile(valRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp pattern \"%s\": %w", valRegexp, err)
+	}
+	return compiled, nil
+}
+
 // globToRegexp converts a glob expression into a regular expression.
 func globToRegexp(glob string) (string, error) {
 	var result strings.Builder
@@ -0,0 +1,541 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+// testGraph builds a graph of marathon_app deployments with the given names
+// and edges, each written as "from -> to" for an edge which does not wait
+// for healthy or "from => to" for one which does, optionally followed by
+// "restart" for an edge with restart_on_change.
+func testGraph(t *testing.T, names string, edges ...string) *Graph {
+	t.Helper()
+	var deployments []Deployment
+	index := make(map[string]int)
+	for _, name := range strings.Fields(names) {
+		index[name] = len(deployments)
+		deployments = append(deployments, Deployment{Type: "marathon_app", Name: name})
+	}
+	for _, edge := range edges {
+		fields := strings.Fields(edge)
+		if len(fields) < 3 || fields[1] != "->" && fields[1] != "=>" {
+			t.Fatalf("invalid edge %q", edge)
+		}
+		i, ok := index[fields[0]]
+		if !ok {
+			t.Fatalf("edge %q from unknown deployment", edge)
+		}
+		deployments[i].Dependencies = append(deployments[i].Dependencies, DependencySpec{
+			Type:            "marathon_app",
+			Name:            fields[2],
+			WaitForHealthy:  fields[1] == "=>",
+			RestartOnChange: len(fields) > 3 && fields[3] == "restart",
+		})
+	}
+	var g Graph
+	if err := g.Build(deployments...); err != nil {
+		t.Fatal(err)
+	}
+	return &g
+}
+
+// testRef returns the DeploymentRef of the marathon_app with the given name.
+func testRef(name string) DeploymentRef {
+	return DeploymentRef{Type: "marathon_app", Name: name}
+}
+
+// formatEdges writes edges in the form accepted by testGraph, separated by
+// commas.
+func formatEdges(edges []Edge) string {
+	formatted := make([]string, len(edges))
+	for i, edge := range edges {
+		arrow := "->"
+		if edge.WaitForHealthy {
+			arrow = "=>"
+		}
+		formatted[i] = edge.From.Name + " " + arrow + " " + edge.To.Name
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// formatRefs writes the names of refs separated by spaces.
+func formatRefs(refs []DeploymentRef) string {
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = ref.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestShortestPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    string
+		edges    []string
+		from, to string
+		path     string
+	}{
+		{
+			name:  "direct",
+			names: "a b",
+			edges: []string{"a -> b"},
+			from:  "a", to: "b",
+			path: "a -> b",
+		},
+		{
+			name:  "shortest",
+			names: "a b c d",
+			edges: []string{"a -> b", "b -> c", "c -> d", "a => c"},
+			from:  "a", to: "d",
+			path: "a => c, c -> d",
+		},
+		{
+			name:  "earliest declared",
+			names: "a b c d",
+			edges: []string{"a -> c", "a -> b", "b -> d", "c -> d"},
+			from:  "a", to: "d",
+			path: "a -> b, b -> d",
+		},
+		{
+			name:  "no path",
+			names: "a b c",
+			edges: []string{"a -> b", "c -> b"},
+			from:  "a", to: "c",
+			path: "",
+		},
+		{
+			name:  "reverse",
+			names: "a b",
+			edges: []string{"a -> b"},
+			from:  "b", to: "a",
+			path: "",
+		},
+		{
+			name:  "self edge",
+			names: "a b",
+			edges: []string{"a -> b", "b -> a", "a => a"},
+			from:  "a", to: "a",
+			path: "a => a",
+		},
+		{
+			name:  "self through cycle",
+			names: "a b c",
+			edges: []string{"a -> b", "b -> c", "c -> a"},
+			from:  "a", to: "a",
+			path: "a -> b, b -> c, c -> a",
+		},
+		{
+			name:  "self without cycle",
+			names: "a b",
+			edges: []string{"a -> b"},
+			from:  "a", to: "a",
+			path: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			path, err := g.ShortestPath(testRef(test.from), testRef(test.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := formatEdges(path); actual != test.path {
+				t.Errorf("got %q, want %q", actual, test.path)
+			}
+		})
+	}
+	g := testGraph(t, "a")
+	if _, err := g.ShortestPath(testRef("a"), testRef("missing")); err == nil {
+		t.Error("expected error for deployment not in graph")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  string
+		edges  []string
+		cycles []string
+	}{
+		{
+			name:  "acyclic",
+			names: "a b c",
+			edges: []string{"a -> b", "b -> c", "a -> c"},
+		},
+		{
+			name:   "self",
+			names:  "a",
+			edges:  []string{"a => a"},
+			cycles: []string{"a => a"},
+		},
+		{
+			name:   "triangle",
+			names:  "a b c",
+			edges:  []string{"a -> b", "b -> c", "c -> a"},
+			cycles: []string{"a -> b, b -> c, c -> a"},
+		},
+		{
+			name:   "starts from earliest declared",
+			names:  "a b c",
+			edges:  []string{"c -> b", "b -> c"},
+			cycles: []string{"b -> c, c -> b"},
+		},
+		{
+			name:  "two cycles sharing an edge",
+			names: "a b c d",
+			edges: []string{"a -> b", "b -> c", "c -> a", "b -> d", "d -> a"},
+			cycles: []string{
+				"a -> b, b -> c, c -> a",
+				"a -> b, b -> d, d -> a",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			cycles := g.Cycles()
+			actual := make([]string, len(cycles))
+			for i, cycle := range cycles {
+				actual[i] = formatEdges(cycle)
+			}
+			if strings.Join(actual, "; ") != strings.Join(test.cycles, "; ") {
+				t.Errorf("got %q, want %q", actual, test.cycles)
+			}
+		})
+	}
+}
+
+func TestCycleString(t *testing.T) {
+	g := testGraph(t, "a b", "a -> b", "b -> a")
+	cycles := g.Cycles()
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1", len(cycles))
+	}
+	const expected = "marathon_app.a -> marathon_app.b -> marathon_app.a"
+	if actual := cycles[0].String(); actual != expected {
+		t.Errorf("got %q, want %q", actual, expected)
+	}
+}
+
+func TestCycleBreakingEdges(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    string
+		edges    []string
+		breaking string
+	}{
+		{
+			name:  "acyclic",
+			names: "a b",
+			edges: []string{"a -> b"},
+		},
+		{
+			name:     "self",
+			names:    "a b",
+			edges:    []string{"a -> b", "b => b"},
+			breaking: "b => b",
+		},
+		{
+			name:     "shared edge",
+			names:    "a b c d",
+			edges:    []string{"a -> b", "b -> c", "c -> a", "b -> d", "d -> a"},
+			breaking: "a -> b",
+		},
+		{
+			name:     "disjoint cycles",
+			names:    "a b c d",
+			edges:    []string{"a -> b", "b -> a", "c -> d", "d -> c"},
+			breaking: "a -> b, c -> d",
+		},
+		{
+			name:  "every pair",
+			names: "a b c",
+			// one edge of each pair and of each triangle must be removed
+			edges:    []string{"a -> b", "b -> a", "b -> c", "c -> b", "c -> a", "a -> c"},
+			breaking: "a -> b, a -> c, b -> c",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			edges := g.CycleBreakingEdges()
+			if actual := formatEdges(edges); actual != test.breaking {
+				t.Errorf("got %q, want %q", actual, test.breaking)
+			}
+			var remaining []string
+			removed := make(map[string]bool)
+			for _, edge := range edges {
+				removed[formatEdges([]Edge{edge})] = true
+			}
+			for _, edge := range test.edges {
+				if !removed[edge] {
+					remaining = append(remaining, edge)
+				}
+			}
+			if cycles := testGraph(t, test.names, remaining...).Cycles(); len(cycles) != 0 {
+				t.Errorf("cycles remain after removing edges: %v", cycles)
+			}
+		})
+	}
+}
+
+func TestTransitiveReduction(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   string
+		edges   []string
+		reduced string
+	}{
+		{
+			name:    "chain",
+			names:   "a b c",
+			edges:   []string{"a -> b", "b -> c", "a -> c"},
+			reduced: "a -> b, b -> c",
+		},
+		{
+			name:    "waiting edge implied by waiting path",
+			names:   "a b c",
+			edges:   []string{"a => b", "b => c", "a => c"},
+			reduced: "a => b, b => c",
+		},
+		{
+			name:    "waiting edge not implied by non-waiting path",
+			names:   "a b c",
+			edges:   []string{"a -> b", "b => c", "a => c"},
+			reduced: "a -> b, a => c, b => c",
+		},
+		{
+			name:    "non-waiting edge implied by any path",
+			names:   "a b c",
+			edges:   []string{"a -> b", "b => c", "a -> c"},
+			reduced: "a -> b, b => c",
+		},
+		{
+			name:    "long path",
+			names:   "a b c d e",
+			edges:   []string{"a -> b", "b -> c", "c -> d", "d -> e", "a -> e", "b -> e"},
+			reduced: "a -> b, b -> c, c -> d, d -> e",
+		},
+		{
+			name:    "diamond",
+			names:   "a b c d",
+			edges:   []string{"a -> b", "a -> c", "b -> d", "c -> d", "a -> d"},
+			reduced: "a -> b, a -> c, b -> d, c -> d",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			reduced, err := g.TransitiveReduction()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := formatEdges(reduced.Edges()); actual != test.reduced {
+				t.Errorf("got %q, want %q", actual, test.reduced)
+			}
+			if len(g.Edges()) != len(test.edges) {
+				t.Errorf("original graph was modified")
+			}
+		})
+	}
+	g := testGraph(t, "a b", "a -> b", "b -> a")
+	if _, err := g.TransitiveReduction(); err == nil {
+		t.Error("expected error for graph with cycles")
+	}
+}
+
+func TestWaves(t *testing.T) {
+	tests := []struct {
+		name  string
+		names string
+		edges []string
+		waves []string
+	}{
+		{
+			name:  "independent",
+			names: "c a b",
+			waves: []string{"a b c"},
+		},
+		{
+			name:  "chain",
+			names: "a b c",
+			edges: []string{"a -> b", "b => c"},
+			waves: []string{"c", "b", "a"},
+		},
+		{
+			name:  "after latest dependency",
+			names: "a b c d",
+			edges: []string{"a -> b", "b -> c", "a -> d"},
+			waves: []string{"c d", "b", "a"},
+		},
+		{
+			name:  "independent of declaration order",
+			names: "d c b a",
+			edges: []string{"a -> b", "b -> c", "a -> d"},
+			waves: []string{"c d", "b", "a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := testGraph(t, test.names, test.edges...)
+			waves, err := g.Waves()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := make([]string, len(waves))
+			for i, wave := range waves {
+				actual[i] = formatRefs(wave)
+			}
+			if strings.Join(actual, "; ") != strings.Join(test.waves, "; ") {
+				t.Errorf("got %q, want %q", actual, test.waves)
+			}
+			order, err := g.DeployOrder()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := formatRefs(order); actual != strings.Join(test.waves, " ") {
+				t.Errorf("got deploy order %q, want %q", actual, strings.Join(test.waves, " "))
+			}
+		})
+	}
+	g := testGraph(t, "a", "a -> a")
+	if _, err := g.Waves(); err == nil {
+		t.Error("expected error for graph with cycles")
+	}
+}
+
+func TestWaitingFrom(t *testing.T) {
+	g := testGraph(t, "a b c", "a -> b", "a -> c", "b -> c")
+	waiting, err := g.WaitingFrom([]DeploymentRef{testRef("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := formatEdges(waiting.Edges()); actual != "a => b, a => c, b -> c" {
+		t.Errorf("got %q", actual)
+	}
+	if actual := formatEdges(g.Edges()); actual != "a -> b, a -> c, b -> c" {
+		t.Errorf("original graph was modified: %q", actual)
+	}
+	// a => c is no longer implied by a path which does not wait
+	reduced, err := waiting.TransitiveReduction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := formatEdges(reduced.Edges()); actual != "a => b, a => c, b -> c" {
+		t.Errorf("got reduction %q", actual)
+	}
+	if _, err := g.WaitingFrom([]DeploymentRef{testRef("missing")}); err == nil {
+		t.Error("expected error for deployment not in graph")
+	}
+}
+
+func TestWithDependents(t *testing.T) {
+	// a depends on b and c, which depend on d, as does e
+	g := testGraph(t, "a b c d e f", "a -> b", "a => c", "b -> d", "c -> d", "e -> d")
+	tests := []struct {
+		deployments string
+		result      string
+	}{
+		{deployments: "a", result: "a"},
+		{deployments: "b", result: "a b"},
+		{deployments: "d", result: "a b c d e"},
+		{deployments: "f b", result: "a b f"},
+		{deployments: "", result: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.deployments, func(t *testing.T) {
+			var refs []DeploymentRef
+			for _, name := range strings.Fields(test.deployments) {
+				refs = append(refs, testRef(name))
+			}
+			result, err := g.WithDependents(refs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := formatRefs(result); actual != test.result {
+				t.Errorf("got %q, want %q", actual, test.result)
+			}
+		})
+	}
+	if _, err := g.WithDependents([]DeploymentRef{testRef("missing")}); err == nil {
+		t.Error("expected error for deployment not in graph")
+	}
+}
+
+func TestDependencySpecTargetsBaseName(t *testing.T) {
+	deployments := []Deployment{
+		{Type: "marathon_app", Name: `worker["eu"]`},
+		{Type: "marathon_app", Name: `worker["us"]`},
+		{Type: "marathon_app", Name: "worker_cleanup"},
+		{Type: "chronos_job", Name: "worker[0]"},
+	}
+	tests := []struct {
+		name    string
+		spec    DependencySpec
+		targets string
+		err     string
+	}{
+		{
+			name:    "base name",
+			spec:    DependencySpec{Type: "marathon_app", Name: "worker"},
+			targets: `worker["eu"] worker["us"]`,
+		},
+		{
+			name:    "full name",
+			spec:    DependencySpec{Type: "marathon_app", Name: `worker["us"]`},
+			targets: `worker["us"]`,
+		},
+		{
+			name:    "count instance",
+			spec:    DependencySpec{Type: "chronos_job", Name: "worker"},
+			targets: "worker[0]",
+		},
+		{
+			name: "unknown instance",
+			spec: DependencySpec{Type: "marathon_app", Name: `worker["ap"]`},
+			err:  `dependent deployment marathon_app.worker["ap"] not found`,
+		},
+		{
+			name: "name filter",
+			spec: DependencySpec{Type: "*", Filters: []Filter{
+				{Key: "name", Value: "worker"},
+			}},
+			targets: `worker["eu"] worker["us"] worker[0]`,
+		},
+		{
+			name: "negated name filter",
+			spec: DependencySpec{Type: "*", Filters: []Filter{
+				{Key: "name", Value: "worker", Negate: true},
+			}},
+			targets: "worker_cleanup",
+		},
+		{
+			name: "glob name filter",
+			spec: DependencySpec{Type: "marathon_app", Filters: []Filter{
+				{Key: "name", Value: "worker", Glob: true},
+			}},
+			targets: `worker["eu"] worker["us"]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indices, err := test.spec.Targets(deployments)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(indices))
+			for i, index := range indices {
+				names[i] = deployments[index].Name
+			}
+			if actual := strings.Join(names, " "); actual != test.targets {
+				t.Errorf("got targets %s, want %s", actual, test.targets)
+			}
+		})
+	}
+}
@@ -1,22 +1,52 @@
 package model
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
 )
 
 var regexpValidIdentifier = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
 
+var regexpValidDeploymentName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\[([0-9]+|"([^"\\]|\\.)*")\])?$`)
+
 // IsValidIdentifier returns true if and only if s is a valid identifier.
 func IsValidIdentifier(s string) bool {
 	return regexpValidIdentifier.MatchString(s)
 }
 
+// IsValidDeploymentName returns true if and only if s is a valid identifier,
+// optionally followed by an instance key in brackets as produced by the
+// for_each and count meta-arguments, e.g. worker["us"] or worker[0].
+func IsValidDeploymentName(s string) bool {
+	return regexpValidDeploymentName.MatchString(s)
+}
+
+// BaseName returns the name of a deployment without its instance key, if
+// any, e.g. the base name of worker["us"] is worker.
+func BaseName(name string) string {
+	if i := strings.IndexByte(name, '['); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
 // Root is the root of a declarative configuration, consisting of a mesos
-// block, one or more framework blocks, and one or more deployment blocks.
+// block, one or more framework blocks, one or more deployment blocks, and zero
+// or more defaults blocks.
+// Root is decoded by DecodeFile or DecodeBody rather than directly by gohcl,
+// since deployment blocks may be expanded into multiple deployments.
+// Dir is the directory of the configuration file, set by DecodeFile, against
+// which the relative paths of definition files are resolved.
 type Root struct {
-	Mesos       *Mesos       `hcl:"mesos,block"`
-	Frameworks  []Framework  `hcl:"framework,block"`
-	Deployments []Deployment `hcl:"deployment,block"`
+	Mesos       *Mesos
+	Frameworks  []Framework
+	Deployments []Deployment
+	Defaults    []Defaults
+	Dir         string
 }
 
 // Mesos is a block that specifies the parameters of an Apache Mesos cluster.
@@ -33,12 +63,18 @@ type FrameworkRef struct {
 
 // Framework is a block that specifies the parameters of a Mesos framework,
 // such as Marathon or Chronos.
+// The fields of type hcl.Range are set by DecodeBody to the source locations
+// of the block and its parts, for use in diagnostics.
 type Framework struct {
 	Type                string         `hcl:"type,label"`
 	Name                string         `hcl:"name,label"`
 	MesosName           string         `hcl:"mesos_name,attr"`
 	Masters             []string       `hcl:"masters,attr"`
 	CreatedByDeployment *DeploymentRef `hcl:"created_by_deployment,block"`
+	DeclRange           hcl.Range
+	TypeRange           hcl.Range
+	NameRange           hcl.Range
+	CreatedByRange      hcl.Range
 }
 
 // Ref returns the FrameworkRef for f.
@@ -55,17 +91,53 @@ type DeploymentRef struct {
 	Name string `hcl:"name,attr"`
 }
 
+// ParseDeploymentRef parses a DeploymentRef from a string of the form
+// type.name, e.g. marathon_app.web or marathon_app.worker["us"].
+func ParseDeploymentRef(s string) (DeploymentRef, error) {
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 || !IsValidIdentifier(parts[0]) || !IsValidDeploymentName(parts[1]) {
+		return DeploymentRef{}, fmt.Errorf("invalid deployment reference \"%s\", expected type.name", s)
+	}
+	return DeploymentRef{
+		Type: parts[0],
+		Name: parts[1],
+	}, nil
+}
+
 // Deployment is a block that defines the parameters of a deployment into
 // a Mesos framework.
 // If the framework is not specified, it is identical to the value "default".
+// A deployment block with a for_each or count meta-argument is decoded into
+// one Deployment per instance, each named with its instance key.
+// The definition of the deployment is either read from the file named by
+// Deploy or given inline as an object by Definition; exactly one of them must
+// be set.
+// If TemplateVars is set, the file named by Deploy is rendered as an HCL
+// template with those variables, as if by the templatefile function.
+// Override is decoded from the attributes of an optional override block and
+// is merged over the definition, after any matching Defaults.
+// The fields of type hcl.Range are set by DecodeBody to the source locations
+// of the block and its parts, for use in diagnostics.
 type Deployment struct {
-	Type         string           `hcl:"type,label"`
-	Name         string           `hcl:"name,label"`
-	Framework    string           `hcl:"framework,optional"`
-	Deploy       string           `hcl:"deploy,attr"`
-	Labels       []string         `hcl:"labels,optional"`
-	Dependencies []DependencySpec `hcl:"dependency,block"`
-	DependencyOf []DependencySpec `hcl:"dependency_of,block"`
+	Type                    string           `hcl:"type,label"`
+	Name                    string           `hcl:"name,label"`
+	Framework               string           `hcl:"framework,optional"`
+	Deploy                  string           `hcl:"deploy,optional"`
+	Definition              cty.Value        `hcl:"definition,optional"`
+	TemplateVars            cty.Value        `hcl:"template_vars,optional"`
+	Labels                  []string         `hcl:"labels,optional"`
+	ExpectedDeployTime      string           `hcl:"expected_deploy_time,optional"`
+	ExpectedHealthTime      string           `hcl:"expected_health_time,optional"`
+	Dependencies            []DependencySpec `hcl:"dependency,block"`
+	DependencyOf            []DependencySpec `hcl:"dependency_of,block"`
+	Override                cty.Value
+	DeclRange               hcl.Range
+	TypeRange               hcl.Range
+	NameRange               hcl.Range
+	FrameworkRange          hcl.Range
+	DefinitionRange         hcl.Range
+	ExpectedDeployTimeRange hcl.Range
+	ExpectedHealthTimeRange hcl.Range
 }
 
 // Ref returns the DeploymentRef for d.
@@ -76,6 +148,24 @@ func (d *Deployment) Ref() DeploymentRef {
 	}
 }
 
+// FrameworkRef returns a reference to the framework d is deployed to, which
+// is the framework named "default" of the right type if d does not name one.
+// Returns false if d is of an unknown type.
+func (d *Deployment) FrameworkRef() (FrameworkRef, bool) {
+	frameworkType, ok := frameworkTypesByDeploymentType[d.Type]
+	if !ok {
+		return FrameworkRef{}, false
+	}
+	name := d.Framework
+	if name == "" {
+		name = "default"
+	}
+	return FrameworkRef{
+		Type: frameworkType,
+		Name: name,
+	}, true
+}
+
 // DependencyRef is a block that defines the parameters of a specific
 // dependency relationship to exactly one deployment.
 type DependencyRef struct {
@@ -97,22 +187,70 @@ func (r DependencyRef) DeploymentRef() DeploymentRef {
 // A DependencySpec can take one of two forms: it can be identical to a
 // DependencyRef, or it can omit the dependent's name and use zero or more
 // filter blocks to narrow down the targets.
+// In the former form, a name without an instance key targets every instance
+// of a deployment block expanded with for_each or count.
 // In the latter form, the dependent's type can be specified as "*" to target
 // all types of deployments.
+// If RestartOnChange is true, the dependent deployments are restarted after
+// their dependencies are redeployed.
+// The fields of type hcl.Range are set by DecodeBody.
 type DependencySpec struct {
-	Type           string   `hcl:"type,attr"`
-	Name           string   `hcl:"name,optional"`
-	WaitForHealthy bool     `hcl:"wait_for_healthy,optional"`
-	Filters        []Filter `hcl:"filter,block"`
+	Type            string   `hcl:"type,attr"`
+	Name            string   `hcl:"name,optional"`
+	WaitForHealthy  bool     `hcl:"wait_for_healthy,optional"`
+	RestartOnChange bool     `hcl:"restart_on_change,optional"`
+	Filters         []Filter `hcl:"filter,block"`
+	DeclRange       hcl.Range
+	TypeRange       hcl.Range
+	NameRange       hcl.Range
 }
 
 // Filter is a block that specifies the criteria used to narrow down the
 // targets of a dependency relationship.
+// The "name" key matches both the full name of a deployment and, for
+// instances of an expanded deployment block, its base name.
+// The fields of type hcl.Range are set by DecodeBody.
 type Filter struct {
-	Key    string   `hcl:"key,attr"`
-	Value  string   `hcl:"value,optional"`
-	Values []string `hcl:"values,optional"`
-	Glob   bool     `hcl:"glob,optional"`
-	Regexp bool     `hcl:"regexp,optional"`
-	Negate bool     `hcl:"negate,optional"`
+	Key       string   `hcl:"key,attr"`
+	Value     string   `hcl:"value,optional"`
+	Values    []string `hcl:"values,optional"`
+	Glob      bool     `hcl:"glob,optional"`
+	Regexp    bool     `hcl:"regexp,optional"`
+	Negate    bool     `hcl:"negate,optional"`
+	DeclRange hcl.Range
+	KeyRange  hcl.Range
+}
+
+// Matches returns true if and only if f matches the given deployment.
+// Returns a non-nil error if f is invalid.
+func (f *Filter) Matches(deployment *Deployment) (bool, error) {
+	return filterMatches(f, deployment)
+}
+
+// Defaults is a block that specifies a partial definition which is merged
+// into the definitions of the deployments it matches.
+// A Defaults block matches deployments in the same way as a DependencySpec
+// without a name, except that the type may be omitted to match all types.
+// The fields of type hcl.Range are set by DecodeBody.
+type Defaults struct {
+	Type            string    `hcl:"type,optional"`
+	Filters         []Filter  `hcl:"filter,block"`
+	Definition      cty.Value `hcl:"definition,attr"`
+	DeclRange       hcl.Range
+	TypeRange       hcl.Range
+	DefinitionRange hcl.Range
+}
+
+// Matches returns true if and only if d matches the given deployment.
+// Returns a non-nil error if any of the filters of d are invalid.
+func (d *Defaults) Matches(deployment *Deployment) (bool, error) {
+	if d.Type != "" && d.Type != "*" && d.Type != deployment.Type {
+		return false, nil
+	}
+	for i := range d.Filters {
+		if matches, err := d.Filters[i].Matches(deployment); err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
 }
@@ -0,0 +1,328 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// frameworkTypesByDeploymentType maps each supported deployment type to the
+// type of framework it is deployed into.
+var frameworkTypesByDeploymentType = map[string]string{
+	"marathon_app": "marathon",
+	"chronos_job":  "chronos",
+}
+
+// Validate checks r for problems that are not detected while decoding, such
+// as invalid types and names, duplicates, and references to frameworks or
+// deployments that do not exist.
+// Every problem found is returned, each with the source range it refers to.
+// If Validate returns no errors, building a Graph from r.Deployments will
+// not fail, though the graph may still have cycles.
+func (r *Root) Validate() hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	// validate and index frameworks
+	frameworksByRef := make(map[FrameworkRef]*Framework, len(r.Frameworks))
+	for i := range r.Frameworks {
+		framework := &r.Frameworks[i]
+		switch framework.Type {
+		case "marathon", "chronos":
+			// ok
+		default:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid framework type",
+				Detail: fmt.Sprintf("Framework type \"%s\" is not supported, only \"marathon\" and \"chronos\" are.%s",
+					framework.Type, didYouMean(framework.Type, []string{"marathon", "chronos"})),
+				Subject: framework.TypeRange.Ptr(),
+			})
+		}
+		if !IsValidIdentifier(framework.Name) {
+			diags = append(diags, invalidNameDiagnostic("framework", framework.Name, framework.NameRange))
+		}
+		if existing, exists := frameworksByRef[framework.Ref()]; exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate framework",
+				Detail: fmt.Sprintf("Framework %s.%s was already declared at %s.", framework.Type, framework.Name,
+					existing.DeclRange),
+				Subject: framework.DeclRange.Ptr(),
+			})
+			continue
+		}
+		frameworksByRef[framework.Ref()] = framework
+	}
+	// validate and index deployments
+	deploymentsByRef := make(map[DeploymentRef]*Deployment, len(r.Deployments))
+	for i := range r.Deployments {
+		deployment := &r.Deployments[i]
+		diags = append(diags, validateDeployment(deployment, r.Frameworks, frameworksByRef)...)
+		if existing, exists := deploymentsByRef[deployment.Ref()]; exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate deployment",
+				Detail: fmt.Sprintf("Deployment %s.%s was already declared at %s.", deployment.Type,
+					deployment.Name, existing.DeclRange),
+				Subject: deployment.DeclRange.Ptr(),
+			})
+			continue
+		}
+		deploymentsByRef[deployment.Ref()] = deployment
+	}
+	// validate references to deployments
+	for i := range r.Frameworks {
+		framework := &r.Frameworks[i]
+		if framework.CreatedByDeployment == nil {
+			continue
+		}
+		ref := *framework.CreatedByDeployment
+		if _, exists := deploymentsByRef[ref]; !exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Reference to undeclared deployment",
+				Detail: fmt.Sprintf("Framework %s.%s is created by deployment %s.%s, which is not declared.",
+					framework.Type, framework.Name, ref.Type, ref.Name),
+				Subject: framework.CreatedByRange.Ptr(),
+			})
+		}
+	}
+	for i := range r.Deployments {
+		deployment := &r.Deployments[i]
+		for j := range deployment.Dependencies {
+			diags = append(diags, validateDependencySpec(&deployment.Dependencies[j], r.Deployments)...)
+		}
+		for j := range deployment.DependencyOf {
+			diags = append(diags, validateDependencySpec(&deployment.DependencyOf[j], r.Deployments)...)
+		}
+	}
+	// validate defaults
+	for i := range r.Defaults {
+		defaults := &r.Defaults[i]
+		switch defaults.Type {
+		case "", "*", "marathon_app", "chronos_job":
+			// ok
+		default:
+			diags = append(diags, invalidDeploymentTypeDiagnostic(defaults.Type, true, defaults.TypeRange))
+		}
+		if ty := defaults.Definition.Type(); !ty.IsObjectType() && !ty.IsMapType() {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid defaults definition",
+				Detail:   "The definition of a defaults block must be an object.",
+				Subject:  defaults.DefinitionRange.Ptr(),
+			})
+		}
+		for j := range defaults.Filters {
+			diags = append(diags, validateFilter(&defaults.Filters[j])...)
+		}
+	}
+	return diags
+}
+
+// validateDeployment checks a single deployment, including whether its
+// framework exists in frameworksByRef, but not its dependencies.
+func validateDeployment(deployment *Deployment, frameworks []Framework,
+	frameworksByRef map[FrameworkRef]*Framework) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	frameworkRef, ok := deployment.FrameworkRef()
+	if !ok {
+		diags = append(diags, invalidDeploymentTypeDiagnostic(deployment.Type, false, deployment.TypeRange))
+	}
+	if !IsValidIdentifier(BaseName(deployment.Name)) || !IsValidDeploymentName(deployment.Name) {
+		diags = append(diags, invalidNameDiagnostic("deployment", deployment.Name, deployment.NameRange))
+	}
+	if _, exists := frameworksByRef[frameworkRef]; ok && !exists {
+		var frameworkNames []string
+		for i := range frameworks {
+			if frameworks[i].Type == frameworkRef.Type {
+				frameworkNames = append(frameworkNames, frameworks[i].Name)
+			}
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Reference to undeclared framework",
+			Detail: fmt.Sprintf("No framework %s.%s is declared for deployment %s.%s.%s", frameworkRef.Type,
+				frameworkRef.Name, deployment.Type, deployment.Name, didYouMean(frameworkRef.Name, frameworkNames)),
+			Subject: deployment.FrameworkRange.Ptr(),
+		})
+	}
+	hasDefinition := !deployment.Definition.IsNull()
+	if deployment.Deploy == "" && !hasDefinition {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing definition",
+			Detail: fmt.Sprintf("Deployment %s.%s must have one of the deploy or definition attributes.",
+				deployment.Type, deployment.Name),
+			Subject: deployment.DeclRange.Ptr(),
+		})
+	} else if deployment.Deploy != "" && hasDefinition {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting definitions",
+			Detail: fmt.Sprintf("Deployment %s.%s can have the deploy or definition attribute, but not both.",
+				deployment.Type, deployment.Name),
+			Subject: deployment.DefinitionRange.Ptr(),
+		})
+	} else if hasDefinition && !deployment.TemplateVars.IsNull() {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Template variables with inline definition",
+			Detail: fmt.Sprintf("Deployment %s.%s cannot have template_vars with an inline definition.",
+				deployment.Type, deployment.Name),
+			Subject: deployment.DefinitionRange.Ptr(),
+		})
+	} else if ty := deployment.Definition.Type(); hasDefinition && !ty.IsObjectType() && !ty.IsMapType() {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid definition",
+			Detail: fmt.Sprintf("The definition of deployment %s.%s must be an object.", deployment.Type,
+				deployment.Name),
+			Subject: deployment.DefinitionRange.Ptr(),
+		})
+	}
+	diags = append(diags, validateExpectedTime("expected_deploy_time", deployment.ExpectedDeployTime,
+		deployment.ExpectedDeployTimeRange)...)
+	diags = append(diags, validateExpectedTime("expected_health_time", deployment.ExpectedHealthTime,
+		deployment.ExpectedHealthTimeRange)...)
+	return diags
+}
+
+// validateExpectedTime checks the value of an expected duration attribute,
+// which may be empty.
+func validateExpectedTime(name, value string, subject hcl.Range) hcl.Diagnostics {
+	if value == "" {
+		return nil
+	}
+	if duration, err := time.ParseDuration(value); err != nil || duration < 0 {
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid duration",
+			Detail: fmt.Sprintf("The value of %s must be a non-negative duration such as \"90s\" or "+
+				"\"2m30s\", not \"%s\".", name, value),
+			Subject: subject.Ptr(),
+		}}
+	}
+	return nil
+}
+
+// validateDependencySpec checks a single dependency spec, including whether
+// its named target exists in deployments.
+func validateDependencySpec(spec *DependencySpec, deployments []Deployment) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	switch spec.Type {
+	case "*", "marathon_app", "chronos_job":
+		// ok
+	default:
+		return append(diags, invalidDeploymentTypeDiagnostic(spec.Type, true, spec.TypeRange))
+	}
+	for i := range spec.Filters {
+		diags = append(diags, validateFilter(&spec.Filters[i])...)
+	}
+	if spec.Name == "" {
+		return diags
+	}
+	if len(spec.Filters) != 0 {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting dependency targets",
+			Detail:   "A dependency can have a name attribute or filter blocks, but not both.",
+			Subject:  spec.DeclRange.Ptr(),
+		})
+	} else if spec.Type == "*" {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid dependency type",
+			Detail:   "A dependency cannot have type \"*\" if its name attribute is set.",
+			Subject:  spec.TypeRange.Ptr(),
+		})
+	}
+	// with the type and filters already checked, the only possible error is
+	// that no deployment matches the name
+	if _, err := findDependents(spec, deployments); err != nil {
+		var deploymentNames []string
+		for i := range deployments {
+			if deployment := &deployments[i]; deployment.Type == spec.Type {
+				if baseName := BaseName(deployment.Name); baseName != deployment.Name {
+					deploymentNames = append(deploymentNames, baseName)
+				}
+				deploymentNames = append(deploymentNames, deployment.Name)
+			}
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Reference to undeclared deployment",
+			Detail: fmt.Sprintf("No deployment %s.%s is declared.%s", spec.Type, spec.Name,
+				didYouMean(spec.Name, deploymentNames)),
+			Subject: spec.NameRange.Ptr(),
+		})
+	}
+	return diags
+}
+
+// validateFilter checks a single filter, including whether its patterns are
+// valid.
+func validateFilter(filter *Filter) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	switch filter.Key {
+	case "name", "labels":
+		// ok
+	default:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid filter key",
+			Detail: fmt.Sprintf("Filter key \"%s\" is not supported, only \"name\" and \"labels\" are.%s",
+				filter.Key, didYouMean(filter.Key, []string{"name", "labels"})),
+			Subject: filter.KeyRange.Ptr(),
+		})
+	}
+	if filter.Value == "" && len(filter.Values) == 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing filter value",
+			Detail:   "A filter must have one of the value or values attributes.",
+			Subject:  filter.DeclRange.Ptr(),
+		})
+	}
+	if filter.Glob || filter.Regexp {
+		for _, val := range filter.values() {
+			if _, err := filterPattern(filter, val); err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid filter pattern",
+					Detail:   fmt.Sprintf("%s.", err),
+					Subject:  filter.DeclRange.Ptr(),
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// invalidDeploymentTypeDiagnostic returns a diagnostic for an unsupported
+// deployment type, mentioning "*" if wildcard is true.
+func invalidDeploymentTypeDiagnostic(deploymentType string, wildcard bool, subject hcl.Range) *hcl.Diagnostic {
+	supported := "\"marathon_app\" and \"chronos_job\" are"
+	if wildcard {
+		supported = "\"*\", \"marathon_app\", and \"chronos_job\" are"
+	}
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid deployment type",
+		Detail: fmt.Sprintf("Deployment type \"%s\" is not supported, only %s.%s", deploymentType, supported,
+			didYouMean(deploymentType, []string{"marathon_app", "chronos_job"})),
+		Subject: subject.Ptr(),
+	}
+}
+
+// invalidNameDiagnostic returns a diagnostic for an invalid name of the given
+// kind of block.
+func invalidNameDiagnostic(kind, name string, subject hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Invalid %s name", kind),
+		Detail: fmt.Sprintf("The %s name \"%s\" is not valid; names must start with a letter or underscore "+
+			"and contain only letters, digits, and underscores.", kind, name),
+		Subject: subject.Ptr(),
+	}
+}
@@ -0,0 +1,273 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// validatePrelude declares the frameworks used by the validation tests; it
+// is appended to the source of each test so that line numbers in the test
+// source start at 1.
+const validatePrelude = `
+framework "marathon" "default" {
+  mesos_name = "marathon"
+  masters    = []
+}
+
+framework "chronos" "default" {
+  mesos_name = "chronos"
+  masters    = []
+}
+`
+
+func TestRootValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		diags  []string
+		detail string
+	}{
+		{
+			name: "valid",
+			src: `deployment "marathon_app" "web" {
+  deploy               = "web.json"
+  expected_deploy_time = "90s"
+  dependency {
+    type = "marathon_app"
+    name = "worker"
+  }
+}
+deployment "marathon_app" "worker" {
+  for_each   = { us = 1, eu = 2 }
+  definition = { instances = each.value }
+}
+deployment "chronos_job" "backup" {
+  deploy = "backup.json"
+  dependency {
+    type = "*"
+    filter {
+      key   = "name"
+      value = "w*"
+      glob  = true
+    }
+  }
+}
+defaults {
+  definition = { cpus = 1 }
+}`,
+		},
+		{
+			name: "deploy and definition",
+			src: `deployment "marathon_app" "web" {
+  deploy     = "web.json"
+  definition = { id = "/web" }
+}`,
+			diags:  []string{"3:16 Conflicting definitions"},
+			detail: "Deployment marathon_app.web can have the deploy or definition attribute, but not both.",
+		},
+		{
+			name:   "neither deploy nor definition",
+			src:    `deployment "marathon_app" "web" {}`,
+			diags:  []string{"1:1 Missing definition"},
+			detail: "Deployment marathon_app.web must have one of the deploy or definition attributes.",
+		},
+		{
+			name: "template_vars with definition",
+			src: `deployment "marathon_app" "web" {
+  definition    = { id = "/web" }
+  template_vars = { a = 1 }
+}`,
+			diags: []string{"2:19 Template variables with inline definition"},
+		},
+		{
+			name: "definition not an object",
+			src: `deployment "marathon_app" "web" {
+  definition = "web.json"
+}`,
+			diags: []string{"2:16 Invalid definition"},
+		},
+		{
+			name: "framework type",
+			src: `framework "marathn" "other" {
+  mesos_name = "other"
+  masters    = []
+}`,
+			diags:  []string{"1:11 Invalid framework type"},
+			detail: `Framework type "marathn" is not supported, only "marathon" and "chronos" are. Did you mean "marathon"?`,
+		},
+		{
+			name: "duplicate framework",
+			src: `framework "marathon" "default" {
+  mesos_name = "marathon"
+  masters    = []
+}`,
+			diags: []string{"6:1 Duplicate framework"},
+		},
+		{
+			name:   "deployment type",
+			src:    `deployment "marathon_ap" "web" { deploy = "web.json" }`,
+			diags:  []string{"1:12 Invalid deployment type"},
+			detail: `Did you mean "marathon_app"?`,
+		},
+		{
+			name:   "deployment name",
+			src:    `deployment "marathon_app" "9web" { deploy = "web.json" }`,
+			diags:  []string{"1:27 Invalid deployment name"},
+			detail: `The deployment name "9web" is not valid`,
+		},
+		{
+			name: "undeclared framework",
+			src: `deployment "marathon_app" "web" {
+  framework = "defualt"
+  deploy    = "web.json"
+}`,
+			diags:  []string{"2:15 Reference to undeclared framework"},
+			detail: `No framework marathon.defualt is declared for deployment marathon_app.web. Did you mean "default"?`,
+		},
+		{
+			name: "duplicate deployment",
+			src: `deployment "marathon_app" "web" { deploy = "web.json" }
+deployment "marathon_app" "web" { deploy = "web.json" }`,
+			diags: []string{"2:1 Duplicate deployment"},
+		},
+		{
+			name: "duplicate instance",
+			src: `deployment "marathon_app" "web" {
+  count  = 1
+  deploy = "web.json"
+}
+deployment "marathon_app" "web[0]" { deploy = "web.json" }`,
+			diags: []string{"5:1 Duplicate deployment"},
+		},
+		{
+			name: "undeclared dependency",
+			src: `deployment "marathon_app" "web" {
+  deploy = "web.json"
+  dependency {
+    type = "marathon_app"
+    name = "wbe"
+  }
+}`,
+			diags:  []string{"5:12 Reference to undeclared deployment"},
+			detail: `No deployment marathon_app.wbe is declared. Did you mean "web"?`,
+		},
+		{
+			name: "undeclared dependency base name",
+			src: `deployment "marathon_app" "web" {
+  deploy = "web.json"
+  dependency {
+    type = "marathon_app"
+    name = "workr"
+  }
+}
+deployment "marathon_app" "worker" {
+  for_each = { us = 1 }
+  deploy   = "worker.json"
+}`,
+			diags:  []string{"5:12 Reference to undeclared deployment"},
+			detail: `Did you mean "worker"?`,
+		},
+		{
+			name: "dependency name and filter",
+			src: `deployment "marathon_app" "web" {
+  deploy = "web.json"
+  dependency {
+    type = "marathon_app"
+    name = "web"
+    filter {
+      key   = "labels"
+      value = "db"
+    }
+  }
+}`,
+			diags: []string{"3:3 Conflicting dependency targets"},
+		},
+		{
+			name: "dependency wildcard name",
+			src: `deployment "marathon_app" "web" {
+  deploy = "web.json"
+  dependency_of {
+    type = "*"
+    name = "web"
+  }
+}`,
+			diags: []string{"4:12 Invalid dependency type"},
+		},
+		{
+			name: "filters",
+			src: `deployment "marathon_app" "web" {
+  deploy = "web.json"
+  dependency {
+    type = "*"
+    filter {
+      key   = "nmae"
+      value = "db"
+    }
+    filter {
+      key = "labels"
+    }
+    filter {
+      key    = "name"
+      value  = "("
+      regexp = true
+    }
+  }
+}`,
+			diags: []string{
+				"6:15 Invalid filter key",
+				"9:5 Missing filter value",
+				"12:5 Invalid filter pattern",
+			},
+			detail: `Filter key "nmae" is not supported, only "name" and "labels" are. Did you mean "name"?`,
+		},
+		{
+			name: "expected times",
+			src: `deployment "marathon_app" "web" {
+  deploy               = "web.json"
+  expected_deploy_time = "fast"
+  expected_health_time = "-1s"
+}`,
+			diags: []string{"3:26 Invalid duration", "4:26 Invalid duration"},
+		},
+		{
+			name: "defaults",
+			src: `defaults {
+  type       = "marathon"
+  definition = ["cpus"]
+}`,
+			diags: []string{"2:16 Invalid deployment type", "3:16 Invalid defaults definition"},
+		},
+		{
+			name: "created by undeclared deployment",
+			src: `framework "marathon" "nested" {
+  mesos_name = "nested"
+  masters    = []
+  created_by_deployment {
+    type = "marathon_app"
+    name = "marathon"
+  }
+}`,
+			diags: []string{"4:3 Reference to undeclared deployment"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, diags := testDecode(t, test.src+"\n"+validatePrelude, nil)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			diags = root.Validate()
+			actual := make([]string, len(diags))
+			for i, diag := range diags {
+				actual[i] = fmt.Sprintf("%d:%d %s", diag.Subject.Start.Line, diag.Subject.Start.Column, diag.Summary)
+			}
+			if strings.Join(actual, ", ") != strings.Join(test.diags, ", ") {
+				t.Fatalf("got diagnostics %q, want %q", actual, test.diags)
+			}
+			if test.detail != "" && !strings.Contains(diags[0].Detail, test.detail) {
+				t.Errorf("got detail %q, want %q", diags[0].Detail, test.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/diff"
+	"github.com/kbolino/mesosdef/model"
+	"github.com/kbolino/mesosdef/planfile"
+	"github.com/kbolino/mesosdef/state"
+)
+
+// action is what apply does with a deployment, as found by comparing its
+// definition with the one currently deployed.
+type action int
+
+const (
+	actionCreate action = iota
+	actionUpdate
+	actionNoOp
+)
+
+func (a action) String() string {
+	switch a {
+	case actionCreate:
+		return "create"
+	case actionUpdate:
+		return "update"
+	case actionNoOp:
+		return "no changes"
+	default:
+		return "unknown"
+	}
+}
+
+// change is the action for a deployment and, for an update, the differences
+// from the definition currently deployed.
+type change struct {
+	action  action
+	changes []diff.Change
+	// liveHash is the hash of the definition currently deployed, if any.
+	liveHash string
+}
+
+// planMain is the entry point for the plan subcommand.
+func planMain(args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	var out string
+	flags.StringVar(&out, "out", "", "save the plan to file, to be applied exactly by apply file")
+	addFrameworkFlags(flags)
+	addSelectionFlags(flags)
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s plan [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Prints the deployments apply would make in waves, where every deployment in\n"+
+			"a wave can be deployed concurrently once the waves before it are done.\n"+
+			"With -live, each deployment is compared with the frameworks to show whether\n"+
+			"it would be created, updated, or left unchanged; otherwise, it is compared\n"+
+			"with the state file, if there is one.\n"+
+			"Exits with %d if there are deployments to make, or %d if there are none.\n\n",
+			exitChanges, exitSuccess)
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkCycles(&cfg.graph); err != nil {
+		return err
+	}
+	selected, err := selectDeployments(cfg)
+	if err != nil {
+		return err
+	}
+	for _, warning := range selected.warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+	}
+	file, err := openState()
+	if err != nil {
+		return err
+	}
+	var changes map[model.DeploymentRef]*change
+	if flagLive {
+		deployer, err := newLiveDeployer(&cfg.root, definition.NewLoader(&cfg.root))
+		if err != nil {
+			return err
+		}
+		if changes, err = planChanges(cfg, selected, compareLive(deployer)); err != nil {
+			return err
+		}
+		if file != nil {
+			if err := warnDrift(selected, changes, file, deployer); err != nil {
+				return err
+			}
+		}
+	} else if file != nil && len(file.Entries()) != 0 {
+		if changes, err = planChanges(cfg, selected, compareState(file)); err != nil {
+			return err
+		}
+	}
+	count, err := printWaves(selected, changes)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if entries := unconfigured(cfg, file); len(entries) != 0 {
+			var names []string
+			for _, entry := range entries {
+				names = append(names, fmt.Sprintf("%s.%s", entry.Type, entry.Name))
+			}
+			fmt.Printf("%d deployment(s) recorded in %s are no longer configured and can be removed by "+
+				"destroy -prune: %s\n", len(entries), flagState, strings.Join(names, ", "))
+		}
+	}
+	if out != "" {
+		saved, err := newPlanFile(cfg, selected, changes)
+		if err != nil {
+			return err
+		}
+		if err := planfile.Write(out, saved); err != nil {
+			return err
+		}
+		fmt.Printf("Saved plan to %s\n", out)
+	}
+	if count == 0 {
+		return nil
+	}
+	return errChanges
+}
+
+// comparer returns the change for a deployment given its definition.
+type comparer func(ref model.DeploymentRef, content []byte) (*change, error)
+
+// planChanges uses compare to find the change for every deployment of
+// selected which is neither existing nor restarted.
+func planChanges(cfg *config, selected *selection, compare comparer) (map[model.DeploymentRef]*change, error) {
+	skipped := make(map[model.DeploymentRef]bool, len(selected.existing)+len(selected.restarted))
+	for _, ref := range selected.existing {
+		skipped[ref] = true
+	}
+	for _, ref := range selected.restarted {
+		skipped[ref] = true
+	}
+	order, err := selected.graph.DeployOrder()
+	if err != nil {
+		return nil, err
+	}
+	loader := definition.NewLoader(&cfg.root)
+	changes := make(map[model.DeploymentRef]*change, len(order))
+	for _, ref := range order {
+		if skipped[ref] {
+			continue
+		}
+		content, err := loader.Load(ref)
+		if err != nil {
+			return nil, err
+		}
+		if changes[ref], err = compare(ref, content); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// compareLive returns a comparer which compares definitions with those
+// fetched from their frameworks by fetcher.
+func compareLive(fetcher deploy.Fetcher) comparer {
+	return func(ref model.DeploymentRef, content []byte) (*change, error) {
+		desired, err := fetcher.Normalize(ref, content)
+		if err != nil {
+			return nil, fmt.Errorf("normalizing definition of %s.%s: %w", ref.Type, ref.Name, err)
+		}
+		current, err := fetcher.Fetch(ref)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s.%s: %w", ref.Type, ref.Name, err)
+		}
+		if current == nil {
+			return &change{action: actionCreate}, nil
+		}
+		differences, err := diff.Compare(current, desired)
+		if err != nil {
+			return nil, fmt.Errorf("comparing definitions of %s.%s: %w", ref.Type, ref.Name, err)
+		}
+		c := &change{action: actionUpdate, changes: differences, liveHash: planfile.Hash(current)}
+		if len(differences) == 0 {
+			c.action = actionNoOp
+		}
+		return c, nil
+	}
+}
+
+// compareState returns a comparer which compares definitions with the hashes
+// of those last deployed, as recorded by file.
+// Updates have no differences, since only the hashes are recorded.
+func compareState(file *state.File) comparer {
+	return func(ref model.DeploymentRef, content []byte) (*change, error) {
+		entry, ok := file.Entry(ref)
+		if !ok {
+			return &change{action: actionCreate}, nil
+		} else if entry.DefinitionHash != state.Hash(content) {
+			return &change{action: actionUpdate}, nil
+		}
+		return &change{action: actionNoOp}, nil
+	}
+}
+
+// warnDrift prints a warning for every deployment with a change which is
+// recorded by file, but has since been removed from its framework or, for a
+// Marathon app, has a version other than the one recorded.
+func warnDrift(selected *selection, changes map[model.DeploymentRef]*change, file *state.File,
+	identifier deploy.Identifier) error {
+	order, err := selected.graph.DeployOrder()
+	if err != nil {
+		return err
+	}
+	for _, ref := range order {
+		c, ok := changes[ref]
+		if !ok {
+			continue
+		}
+		entry, ok := file.Entry(ref)
+		if !ok {
+			continue
+		} else if c.action == actionCreate {
+			fmt.Fprintf(os.Stderr, "WARNING: %s.%s was removed from its framework outside of mesosdef since it "+
+				"was last applied\n", ref.Type, ref.Name)
+			continue
+		} else if entry.MarathonVersion == "" {
+			continue
+		}
+		_, version, err := identifier.Identify(ref)
+		if err != nil {
+			return fmt.Errorf("identifying %s.%s: %w", ref.Type, ref.Name, err)
+		} else if version != "" && version != entry.MarathonVersion {
+			fmt.Fprintf(os.Stderr, "WARNING: %s.%s was changed outside of mesosdef since it was last applied "+
+				"(version %s, now %s)\n", ref.Type, ref.Name, entry.MarathonVersion, version)
+		}
+	}
+	return nil
+}
+
+// unconfigured returns the entries of file for deployments which are not in
+// the configuration of cfg, which can be removed by destroy -prune.
+func unconfigured(cfg *config, file *state.File) []state.Entry {
+	configured := make(map[model.DeploymentRef]bool, len(cfg.root.Deployments))
+	for i := range cfg.root.Deployments {
+		configured[cfg.root.Deployments[i].Ref()] = true
+	}
+	var entries []state.Entry
+	for _, entry := range file.Entries() {
+		if !configured[entry.Ref()] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// newPlanFile returns the plan for the deployments of selected, with the
+// given changes if it was made with -live.
+func newPlanFile(cfg *config, selected *selection, changes map[model.DeploymentRef]*change) (*planfile.Plan,
+	error) {
+	saved := &planfile.Plan{
+		MesosdefVersion: version,
+		ConfigHash:      cfg.hash,
+		Variables:       cfg.variables,
+		Live:            flagLive,
+	}
+	for i := range cfg.root.Frameworks {
+		framework := &cfg.root.Frameworks[i]
+		saved.Frameworks = append(saved.Frameworks, planfile.Framework{
+			Type:    framework.Type,
+			Name:    framework.Name,
+			Masters: framework.Masters,
+		})
+	}
+	actions := make(map[model.DeploymentRef]string)
+	for _, ref := range selected.existing {
+		actions[ref] = planfile.ActionWait
+	}
+	for _, ref := range selected.restarted {
+		actions[ref] = planfile.ActionRestart
+	}
+	order, err := selected.graph.DeployOrder()
+	if err != nil {
+		return nil, err
+	}
+	included := make(map[model.DeploymentRef]bool, len(order))
+	for _, ref := range order {
+		included[ref] = true
+	}
+	loader := definition.NewLoader(&cfg.root)
+	for i := range cfg.root.Deployments {
+		deployment := &cfg.root.Deployments[i]
+		ref := deployment.Ref()
+		if !included[ref] {
+			continue
+		}
+		entry := planfile.Deployment{
+			Type:      ref.Type,
+			Name:      ref.Name,
+			Framework: deployment.Framework,
+			Labels:    deployment.Labels,
+			Action:    actions[ref],
+		}
+		if c, ok := changes[ref]; ok {
+			entry.Action = map[action]string{
+				actionCreate: planfile.ActionCreate,
+				actionUpdate: planfile.ActionUpdate,
+				actionNoOp:   planfile.ActionNoOp,
+			}[c.action]
+			entry.LiveHash = c.liveHash
+		} else if entry.Action == "" {
+			entry.Action = planfile.ActionDeploy
+		}
+		// deployments which are only waited on still need their app ID or
+		// job name, which are given by their definitions
+		content, err := loader.Load(ref)
+		if err != nil {
+			return nil, err
+		}
+		entry.Definition = content
+		saved.Deployments = append(saved.Deployments, entry)
+	}
+	for _, edge := range selected.graph.Edges() {
+		saved.Edges = append(saved.Edges, planfile.Edge{
+			From:            fmt.Sprintf("%s.%s", edge.From.Type, edge.From.Name),
+			To:              fmt.Sprintf("%s.%s", edge.To.Type, edge.To.Name),
+			WaitForHealthy:  edge.WaitForHealthy,
+			RestartOnChange: edge.RestartOnChange,
+		})
+	}
+	return saved, nil
+}
+
+// unchanged returns the deployments which changes says need no changes.
+func unchanged(changes map[model.DeploymentRef]*change) []model.DeploymentRef {
+	var refs []model.DeploymentRef
+	for ref, c := range changes {
+		if c.action == actionNoOp {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// printWaves prints the graph of selected in waves, leaving out implied
+// dependencies, along with the changes to each deployment if changes is not
+// nil, and returns the number of deployments which are not only waited on.
+func printWaves(selected *selection, changes map[model.DeploymentRef]*change) (int, error) {
+	graph := selected.graph
+	notes := make(map[model.DeploymentRef]string)
+	for _, ref := range selected.existing {
+		notes[ref] = " (existing, only waited on until healthy)"
+	}
+	for _, ref := range selected.restarted {
+		notes[ref] = " (restarted once its dependencies are healthy)"
+	}
+	counts := make(map[action]int)
+	for ref, c := range changes {
+		counts[c.action]++
+		if c.action == actionNoOp {
+			notes[ref] = " (no changes, only waited on until healthy)"
+		} else {
+			notes[ref] = fmt.Sprintf(" (%s)", c.action)
+		}
+	}
+	waves, err := graph.Waves()
+	if err != nil {
+		return 0, err
+	}
+	// restarted deployments wait for all of their dependencies to become
+	// healthy, as in GraphDeployer
+	waiting, err := graph.WaitingFrom(selected.restarted)
+	if err != nil {
+		return 0, err
+	}
+	reduced, err := waiting.TransitiveReduction()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for i, wave := range waves {
+		fmt.Printf("wave %d:\n", i+1)
+		for _, deployment := range wave {
+			fmt.Printf("\t%s.%s%s\n", deployment.Type, deployment.Name, notes[deployment])
+			total++
+			dependencies, err := reduced.Dependencies(deployment)
+			if err != nil {
+				return 0, err
+			}
+			for _, dependency := range dependencies {
+				prefix := "immediately after"
+				if dependency.WaitForHealthy {
+					prefix = "after waiting for"
+				}
+				fmt.Printf("\t\t%s %s.%s\n", prefix, dependency.Type, dependency.Name)
+			}
+			if c, ok := changes[deployment]; ok {
+				for _, difference := range c.changes {
+					fmt.Printf("\t\t%s\n", difference)
+				}
+			}
+		}
+	}
+	fmt.Printf("%d of %d dependencies are implied by others and not shown\n",
+		len(graph.Edges())-len(reduced.Edges()), len(graph.Edges()))
+	if changes != nil {
+		fmt.Printf("Plan: %d to create, %d to update, %d to restart, %d unchanged\n", counts[actionCreate],
+			counts[actionUpdate], len(selected.restarted), counts[actionNoOp])
+	}
+	return total - len(selected.existing) - counts[actionNoOp], nil
+}
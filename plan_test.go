@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+	"github.com/kbolino/mesosdef/planfile"
+)
+
+// testConfig is a configuration in which c depends on b, which waits for a
+// to become healthy.
+const testConfig = `
+framework "marathon" "default" {
+    mesos_name = "marathon"
+    masters = ["127.0.0.1:8080"]
+}
+deployment "marathon_app" "a" {
+    definition = { id = "/a" }
+}
+deployment "marathon_app" "b" {
+    definition = { id = "/b" }
+    dependency {
+        type = "marathon_app"
+        name = "a"
+        wait_for_healthy = true
+    }
+}
+deployment "marathon_app" "c" {
+    definition = { id = "/c" }
+    dependency {
+        type = "marathon_app"
+        name = "b"
+    }
+}
+`
+
+// sourceDeployer is a deploy.Deployer which loads the definition of each
+// deployment from source in every phase, the way the live deployer does,
+// and records the phases it runs.
+type sourceDeployer struct {
+	source definition.Source
+	mutex  sync.Mutex
+	phases []string
+}
+
+func (d *sourceDeployer) phase(name string, ref model.DeploymentRef) error {
+	if _, err := d.source.Load(ref); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.phases = append(d.phases, fmt.Sprintf("%s %s.%s", name, ref.Type, ref.Name))
+	return nil
+}
+
+func (d *sourceDeployer) Deploy(ref model.DeploymentRef) error {
+	return d.phase("deploy", ref)
+}
+
+func (d *sourceDeployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	return d.phase("healthy", ref)
+}
+
+// TestPlanFileApply saves a plan with -target and -no-deps, reads it back,
+// and applies it through a deployer loading definitions from the plan.
+func TestPlanFileApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mesosdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	flagFile = filepath.Join(dir, "config.hcl")
+	if err := ioutil.WriteFile(flagFile, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flagNoenv, flagTargets, flagNoDeps, flagMaxDeploy = true, stringSliceValue{"marathon_app.b"}, true, 1
+	t.Cleanup(func() {
+		flagFile, flagNoenv, flagTargets, flagNoDeps, flagMaxDeploy = "", false, nil, false, 0
+	})
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := selectDeployments(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := newPlanFile(cfg, selected, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "plan.bin")
+	if err := planfile.Write(filename, saved); err != nil {
+		t.Fatal(err)
+	}
+	read, err := planfile.Read(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, deployment := range read.Deployments {
+		actions = append(actions, fmt.Sprintf("%s.%s %s", deployment.Type, deployment.Name, deployment.Action))
+	}
+	if got, want := strings.Join(actions, ", "), "marathon_app.a wait, marathon_app.b deploy"; got != want {
+		t.Errorf("got deployments %s, want %s", got, want)
+	}
+	graph, err := read.Graph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployer := &sourceDeployer{source: read}
+	graphDeployer, _, err := newPlanGraphDeployer(read, graph, deployer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan deploy.Event, 100)
+	var failures []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			if event.Type == deploy.EventDeploymentFailure || event.Type == deploy.EventDependencyFailure {
+				failures = append(failures, event.Err.Error())
+			}
+		}
+	}()
+	graphDeployer.Deploy(events)
+	<-done
+	if len(failures) != 0 {
+		t.Errorf("got failures %s", strings.Join(failures, "; "))
+	}
+	sort.Strings(deployer.phases)
+	if got, want := strings.Join(deployer.phases, ", "),
+		"deploy marathon_app.b, healthy marathon_app.a, healthy marathon_app.b"; got != want {
+		t.Errorf("got phases %s, want %s", got, want)
+	}
+}
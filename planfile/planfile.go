@@ -0,0 +1,222 @@
+// Package planfile reads and writes saved plans, which record everything
+// apply needs to make exactly the deployments that were planned without
+// reading the configuration again.
+package planfile
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// FormatVersion is the version of the plan file format written by Write.
+// Read refuses files of any other version.
+const FormatVersion = 1
+
+// Action constants say what apply does with a deployment.
+const (
+	// ActionCreate deploys a deployment which is not deployed.
+	ActionCreate = "create"
+	// ActionUpdate deploys a deployment whose definition has changed.
+	ActionUpdate = "update"
+	// ActionNoOp only waits on a deployment whose definition has not changed.
+	ActionNoOp = "no-op"
+	// ActionDeploy deploys a deployment which was not compared with its
+	// framework, because the plan was not made with -live.
+	ActionDeploy = "deploy"
+	// ActionRestart restarts a deployment because one of its dependencies
+	// changed.
+	ActionRestart = "restart"
+	// ActionWait only waits on a deployment which is assumed to exist.
+	ActionWait = "wait"
+)
+
+// Plan is a saved plan.
+// Deployments and edges refer to deployments by their type.name form.
+type Plan struct {
+	FormatVersion   int    `json:"format_version"`
+	MesosdefVersion string `json:"mesosdef_version"`
+	// ConfigHash is the hash of the configuration file and the values of its
+	// variables.
+	ConfigHash string            `json:"config_hash"`
+	Variables  map[string]string `json:"variables"`
+	// Live is true if the plan was made by comparing deployments with their
+	// frameworks, in which case it is applied to them as well.
+	Live        bool         `json:"live"`
+	Frameworks  []Framework  `json:"frameworks"`
+	Deployments []Deployment `json:"deployments"`
+	Edges       []Edge       `json:"edges"`
+}
+
+// Framework is a framework deployments are made to.
+type Framework struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Masters []string `json:"masters"`
+}
+
+// Deployment is a deployment of the plan along with its action.
+type Deployment struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Framework string   `json:"framework"`
+	Labels    []string `json:"labels,omitempty"`
+	Action    string   `json:"action"`
+	// Definition is the final definition of the deployment, which is
+	// recorded even if the deployment is only waited on, since waiting needs
+	// the app ID or job name it gives.
+	Definition json.RawMessage `json:"definition,omitempty"`
+	// LiveHash is the hash of the normalized definition fetched from the
+	// framework when planning, or empty if it was not deployed or not
+	// fetched.
+	LiveHash string `json:"live_hash,omitempty"`
+}
+
+// Ref returns the DeploymentRef for d.
+func (d *Deployment) Ref() model.DeploymentRef {
+	return model.DeploymentRef{
+		Type: d.Type,
+		Name: d.Name,
+	}
+}
+
+// Edge is a dependency between deployments of the plan.
+type Edge struct {
+	From            string `json:"from"`
+	To              string `json:"to"`
+	WaitForHealthy  bool   `json:"wait_for_healthy"`
+	RestartOnChange bool   `json:"restart_on_change"`
+}
+
+var _ definition.Source = &Plan{}
+
+// Hash returns the hash of content, as recorded by LiveHash.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Root returns a configuration with the frameworks and deployments of p,
+// which is sufficient to create a deployer for them.
+// The deployments have no definitions or dependencies, since those are given
+// by Load and Graph.
+func (p *Plan) Root() *model.Root {
+	root := &model.Root{
+		Frameworks:  make([]model.Framework, len(p.Frameworks)),
+		Deployments: make([]model.Deployment, len(p.Deployments)),
+	}
+	for i := range p.Frameworks {
+		framework := &p.Frameworks[i]
+		root.Frameworks[i] = model.Framework{
+			Type:    framework.Type,
+			Name:    framework.Name,
+			Masters: framework.Masters,
+		}
+	}
+	for i := range p.Deployments {
+		deployment := &p.Deployments[i]
+		root.Deployments[i] = model.Deployment{
+			Type:      deployment.Type,
+			Name:      deployment.Name,
+			Framework: deployment.Framework,
+			Labels:    deployment.Labels,
+		}
+	}
+	return root
+}
+
+// Graph returns the dependency graph of the deployments of p.
+func (p *Plan) Graph() (*model.Graph, error) {
+	refs := make([]model.DeploymentRef, len(p.Deployments))
+	for i := range p.Deployments {
+		refs[i] = p.Deployments[i].Ref()
+	}
+	edges := make([]model.Edge, len(p.Edges))
+	for i := range p.Edges {
+		from, err := model.ParseDeploymentRef(p.Edges[i].From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid edge: %w", err)
+		}
+		to, err := model.ParseDeploymentRef(p.Edges[i].To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid edge: %w", err)
+		}
+		edges[i] = model.Edge{
+			From:            from,
+			To:              to,
+			WaitForHealthy:  p.Edges[i].WaitForHealthy,
+			RestartOnChange: p.Edges[i].RestartOnChange,
+		}
+	}
+	graph := &model.Graph{}
+	if err := graph.BuildFromEdges(refs, edges); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// Load returns the definition of the deployment with the given ref recorded
+// by p.
+func (p *Plan) Load(ref model.DeploymentRef) ([]byte, error) {
+	for i := range p.Deployments {
+		deployment := &p.Deployments[i]
+		if deployment.Type != ref.Type || deployment.Name != ref.Name {
+			continue
+		} else if len(deployment.Definition) == 0 {
+			return nil, fmt.Errorf("plan has no definition of %s.%s", ref.Type, ref.Name)
+		}
+		return deployment.Definition, nil
+	}
+	return nil, fmt.Errorf("deployment %s.%s not in plan", ref.Type, ref.Name)
+}
+
+// Read reads a plan written by Write.
+// Returns a non-nil error if the file is not a plan or has a format version
+// other than FormatVersion.
+func Read(filename string) (*Plan, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan %s: %w", filename, err)
+	}
+	var p Plan
+	if err := json.NewDecoder(reader).Decode(&p); err != nil {
+		return nil, fmt.Errorf("parsing plan %s: %w", filename, err)
+	} else if p.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("plan %s has format version %d, but only version %d is supported", filename,
+			p.FormatVersion, FormatVersion)
+	}
+	return &p, nil
+}
+
+// Write writes p to a file as gzipped JSON, setting its format version.
+func Write(filename string, p *Plan) error {
+	p.FormatVersion = FormatVersion
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	writer := gzip.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(p); err != nil {
+		file.Close()
+		return fmt.Errorf("encoding plan: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	return nil
+}
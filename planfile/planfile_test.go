@@ -0,0 +1,154 @@
+package planfile
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// tempDir returns a directory which is removed when the test finishes.
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "planfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// testPlan returns a plan in which b waits for a to become healthy and is
+// restarted when it changes, and a is only waited on.
+func testPlan() *Plan {
+	return &Plan{
+		MesosdefVersion: "test",
+		ConfigHash:      "0123",
+		Variables:       map[string]string{"env": "prod"},
+		Live:            true,
+		Frameworks: []Framework{
+			{Type: "marathon", Name: "default", Masters: []string{"127.0.0.1:8080"}},
+		},
+		Deployments: []Deployment{
+			{
+				Type:       "marathon_app",
+				Name:       "a",
+				Framework:  "default",
+				Action:     ActionWait,
+				Definition: json.RawMessage(`{"id":"/a"}`),
+			},
+			{
+				Type:       "marathon_app",
+				Name:       "b",
+				Framework:  "default",
+				Labels:     []string{"web"},
+				Action:     ActionUpdate,
+				Definition: json.RawMessage(`{"id":"/b"}`),
+				LiveHash:   Hash([]byte(`{"id":"/b","cpus":2}`)),
+			},
+		},
+		Edges: []Edge{
+			{From: "marathon_app.b", To: "marathon_app.a", WaitForHealthy: true, RestartOnChange: true},
+		},
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "plan.bin")
+	written := testPlan()
+	if err := Write(filename, written); err != nil {
+		t.Fatal(err)
+	}
+	if written.FormatVersion != FormatVersion {
+		t.Errorf("got format version %d, want %d", written.FormatVersion, FormatVersion)
+	}
+	read, err := Read(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, written) {
+		t.Errorf("got %+v, want %+v", read, written)
+	}
+	graph, err := read.Graph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge, ok := graph.Edge(model.DeploymentRef{Type: "marathon_app", Name: "b"},
+		model.DeploymentRef{Type: "marathon_app", Name: "a"})
+	if !ok || !edge.WaitForHealthy || !edge.RestartOnChange {
+		t.Errorf("got edge %+v, want one which waits and restarts", edge)
+	}
+	for _, name := range []string{"a", "b"} {
+		content, err := read.Load(model.DeploymentRef{Type: "marathon_app", Name: name})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if want := `{"id":"/` + name + `"}`; string(content) != want {
+			t.Errorf("%s: got definition %s, want %s", name, content, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := tempDir(t)
+	tests := []struct {
+		name    string
+		content string
+		gzipped bool
+		err     string
+	}{
+		{name: "not gzipped", content: `{"format_version":1}`, err: "reading plan"},
+		{name: "not JSON", content: `plan`, gzipped: true, err: "parsing plan"},
+		{name: "other version", content: `{"format_version":2}`, gzipped: true,
+			err: "has format version 2, but only version 1 is supported"},
+		{name: "current version", content: `{"format_version":1}`, gzipped: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "-"))
+			file, err := os.Create(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.gzipped {
+				writer := gzip.NewWriter(file)
+				writer.Write([]byte(test.content))
+				writer.Close()
+			} else {
+				file.Write([]byte(test.content))
+			}
+			file.Close()
+			_, err = Read(filename)
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+	if _, err := Read(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	p := testPlan()
+	p.Deployments[0].Definition = nil
+	if _, err := p.Load(model.DeploymentRef{Type: "marathon_app", Name: "a"}); err == nil ||
+		err.Error() != "plan has no definition of marathon_app.a" {
+		t.Errorf("got error %v for deployment without definition", err)
+	}
+	if _, err := p.Load(model.DeploymentRef{Type: "marathon_app", Name: "c"}); err == nil ||
+		err.Error() != "deployment marathon_app.c not in plan" {
+		t.Errorf("got error %v for deployment not in plan", err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// simulateMain is the entry point for the simulate subcommand.
+func simulateMain(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	var workers string
+	var runs int
+	var seed int64
+	var jitter float64
+	var defaultEstimate model.Estimate
+	var deployFailure, healthFailure float64
+	var reports stringSliceValue
+	flags.StringVar(&workers, "workers", "1-10", "values of -maxDeploy to simulate, such as 1-10 or 2,4,8")
+	flags.IntVar(&runs, "runs", 200, "number of simulated deployments for each value of -maxDeploy")
+	flags.Int64Var(&seed, "seed", 0, "seed for random durations and failures, 0 for the current time")
+	flags.Float64Var(&jitter, "jitter", 0.25, "fraction by which each duration may vary from its estimate")
+	flags.DurationVar(&defaultEstimate.Deploy, "deploy-time", 30*time.Second,
+		"deploy time of deployments with no estimate")
+	flags.DurationVar(&defaultEstimate.Health, "health-time", 60*time.Second,
+		"health time of deployments with no estimate")
+	flags.Float64Var(&deployFailure, "deploy-failure", 0.01, "probability that a deploy phase fails")
+	flags.Float64Var(&healthFailure, "health-failure", 0.01, "probability that a health phase fails")
+	flags.Var(&reports, "report", "estimate durations from a report written by -report, can be repeated")
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s simulate [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Simulates deployments on a virtual clock to compare values of -maxDeploy.\n"+
+			"Durations are estimated as by graph -critical-path.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	workerCounts, err := parseIntRanges(workers)
+	if err != nil {
+		return fmt.Errorf("invalid -workers: %w", err)
+	} else if runs < 1 {
+		return fmt.Errorf("invalid -runs: must be at least 1")
+	} else if jitter < 0 || jitter > 1 {
+		return fmt.Errorf("invalid -jitter: must be between 0 and 1")
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	estimates, _, err := loadEstimates(cfg, reports)
+	if err != nil {
+		return err
+	}
+	profile := func(estimate model.Estimate) deploy.SimProfile {
+		spread := func(d time.Duration) deploy.Distribution {
+			return deploy.Distribution{
+				Min: time.Duration(float64(d) * (1 - jitter)),
+				Max: time.Duration(float64(d) * (1 + jitter)),
+			}
+		}
+		return deploy.SimProfile{
+			Deploy:        spread(estimate.Deploy),
+			Health:        spread(estimate.Health),
+			DeployFailure: deployFailure,
+			HealthFailure: healthFailure,
+		}
+	}
+	simulation := &deploy.Simulation{
+		Graph:          &cfg.graph,
+		Profiles:       make(map[model.DeploymentRef]deploy.SimProfile, len(estimates)),
+		DefaultProfile: profile(defaultEstimate),
+	}
+	for ref, estimate := range estimates {
+		simulation.Profiles[ref] = profile(estimate)
+	}
+	fmt.Printf("simulating %d run(s) of %d deployment(s) with seed %d\n", runs, len(cfg.root.Deployments), seed)
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "MAXDEPLOY\tMEAN MAKESPAN\tP90 MAKESPAN\tUTILIZATION\tBLOCKED\tMEAN FAILED\n")
+	for _, maxDeploy := range workerCounts {
+		// each value of maxDeploy sees the same random durations and failures
+		rng := rand.New(rand.NewSource(seed))
+		makespans := make([]time.Duration, runs)
+		var total time.Duration
+		var utilization, blocked float64
+		var failed int
+		for i := range makespans {
+			result, err := simulation.Run(maxDeploy, rng)
+			if err != nil {
+				return err
+			}
+			makespans[i] = result.Makespan
+			total += result.Makespan
+			utilization += result.Utilization(maxDeploy)
+			if result.Makespan != 0 {
+				blocked += float64(result.Blocked) / float64(result.Makespan) / float64(maxDeploy)
+			}
+			failed += result.FailedDeployments
+		}
+		sort.Slice(makespans, func(i, j int) bool {
+			return makespans[i] < makespans[j]
+		})
+		n := float64(runs)
+		fmt.Fprintf(out, "%d\t%s\t%s\t%.1f%%\t%.1f%%\t%.2f\n", maxDeploy,
+			(total / time.Duration(runs)).Round(time.Second), makespans[(runs*9-1)/10].Round(time.Second),
+			100*utilization/n, 100*blocked/n, float64(failed)/n)
+	}
+	return out.Flush()
+}
+
+// parseIntRanges parses a comma-separated list of positive integers and
+// ranges of them, such as "1-4,8,16", into a sorted list without duplicates.
+func parseIntRanges(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var result []int
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("\"%s\" is not a number or range", part)
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("\"%s\" is not a number or range", part)
+			}
+		}
+		if low < 1 || high < low {
+			return nil, fmt.Errorf("\"%s\" is not a range of positive numbers", part)
+		}
+		for i := low; i <= high; i++ {
+			if !seen[i] {
+				seen[i] = true
+				result = append(result, i)
+			}
+		}
+	}
+	sort.Ints(result)
+	return result, nil
+}
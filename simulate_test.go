@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseIntRanges(t *testing.T) {
+	tests := []struct {
+		input  string
+		result string
+		err    string
+	}{
+		{input: "4", result: "[4]"},
+		{input: "1-4,8,16", result: "[1 2 3 4 8 16]"},
+		{input: "8, 2-3, 3", result: "[2 3 8]"},
+		{input: "5-5", result: "[5]"},
+		{input: "", err: "\"\" is not a number or range"},
+		{input: "a", err: "\"a\" is not a number or range"},
+		{input: "1-b", err: "\"1-b\" is not a number or range"},
+		{input: "0", err: "\"0\" is not a range of positive numbers"},
+		{input: "4-2", err: "\"4-2\" is not a range of positive numbers"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := parseIntRanges(test.input)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if actual := fmt.Sprint(result); actual != test.result {
+				t.Errorf("got %s, want %s", actual, test.result)
+			}
+		})
+	}
+}
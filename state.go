@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+	"github.com/kbolino/mesosdef/state"
+)
+
+var flagState string
+
+// openState opens the state file given by -state, or returns nil if -state
+// is empty.
+func openState() (*state.File, error) {
+	if flagState == "" {
+		return nil, nil
+	}
+	return state.Open(flagState)
+}
+
+// stateRecorder records deployments in the state file as soon as their
+// deploy phases succeed, so that the state is up to date even if a run is
+// interrupted or a deployment never becomes healthy.
+type stateRecorder struct {
+	file       *state.File
+	root       *model.Root
+	graph      *model.Graph
+	source     definition.Source
+	identifier deploy.Identifier
+	// unchanged holds the existing deployments which are recorded anyway,
+	// because they were compared with their frameworks and had no changes.
+	unchanged map[model.DeploymentRef]bool
+	// err is the first error recording a deployment.
+	err error
+}
+
+// newStateRecorder returns a stateRecorder for the deployments of root, whose
+// dependencies are given by graph and whose definitions are loaded from
+// source, or nil if live is false, -state is empty, or deployer cannot
+// identify deployments.
+func newStateRecorder(root *model.Root, graph *model.Graph, source definition.Source, deployer deploy.Deployer,
+	live bool) (*stateRecorder, error) {
+	identifier, ok := deployer.(deploy.Identifier)
+	if !live || !ok {
+		return nil, nil
+	}
+	file, err := openState()
+	if err != nil || file == nil {
+		return nil, err
+	}
+	return &stateRecorder{
+		file:       file,
+		root:       root,
+		graph:      graph,
+		source:     source,
+		identifier: identifier,
+		unchanged:  make(map[model.DeploymentRef]bool),
+	}, nil
+}
+
+// setUnchanged makes r record refs, which are existing deployments with no
+// changes, as well as the deployments which are deployed, restarted, or
+// destroyed.
+func (r *stateRecorder) setUnchanged(refs ...model.DeploymentRef) {
+	for _, ref := range refs {
+		r.unchanged[ref] = true
+	}
+}
+
+// record updates the state file for event if it is the end of the deploy
+// phase of a deployment which was deployed, restarted, destroyed, or
+// unchanged.
+// Errors are printed as warnings and the first is kept in r.err.
+func (r *stateRecorder) record(event deploy.Event) {
+	if event.Type != deploy.EventDeployed {
+		return
+	}
+	ref := event.Deployment.Ref()
+	if event.Deployment.Existing() && !r.unchanged[ref] {
+		return
+	}
+	var err error
+	if event.Deployment.Destroy() {
+		err = r.file.Delete(ref)
+	} else {
+		err = r.put(ref)
+	}
+	if err != nil {
+		err = fmt.Errorf("recording %s.%s in state: %w", ref.Type, ref.Name, err)
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+		if r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+// put records the deployment of ref in the state file.
+// The update time of an unchanged deployment is kept if it is already
+// recorded with the same definition.
+func (r *stateRecorder) put(ref model.DeploymentRef) error {
+	var frameworkRef model.FrameworkRef
+	for i := range r.root.Deployments {
+		if r.root.Deployments[i].Ref() == ref {
+			frameworkRef, _ = r.root.Deployments[i].FrameworkRef()
+			break
+		}
+	}
+	dependencyRefs, err := r.graph.Dependencies(ref)
+	if err != nil {
+		return err
+	}
+	dependencies := make([]string, len(dependencyRefs))
+	for i, dependency := range dependencyRefs {
+		dependencies[i] = fmt.Sprintf("%s.%s", dependency.Type, dependency.Name)
+	}
+	content, err := r.source.Load(ref)
+	if err != nil {
+		return err
+	}
+	id, version, err := r.identifier.Identify(ref)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	entry := state.Entry{
+		Type:            ref.Type,
+		Name:            ref.Name,
+		Framework:       fmt.Sprintf("%s.%s", frameworkRef.Type, frameworkRef.Name),
+		ID:              id,
+		DefinitionHash:  state.Hash(content),
+		MarathonVersion: version,
+		Dependencies:    dependencies,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if old, ok := r.file.Entry(ref); ok && r.unchanged[ref] && old.DefinitionHash == entry.DefinitionHash {
+		entry.UpdatedAt = old.UpdatedAt
+	}
+	return r.file.Put(entry)
+}
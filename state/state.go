@@ -0,0 +1,264 @@
+// Package state reads and writes the state file, which records what was
+// deployed to the frameworks by earlier runs.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// Version is the version of the state file format written by File.
+// Open refuses files of any other version.
+const Version = 1
+
+// State is the content of a state file.
+// Serial is incremented every time the file is written.
+type State struct {
+	Version     int     `json:"version"`
+	Serial      int64   `json:"serial"`
+	Deployments []Entry `json:"deployments"`
+}
+
+// Entry records a deployment as it was last deployed.
+type Entry struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Framework is the framework the deployment was deployed to, in
+	// type.name form.
+	Framework string `json:"framework"`
+	// ID is the app ID or job name of the deployment in its framework.
+	ID string `json:"id"`
+	// DefinitionHash is the hash of the definition which was deployed, as
+	// returned by Hash.
+	DefinitionHash string `json:"definition_hash"`
+	// MarathonVersion is the version Marathon gave the app when it was
+	// deployed, or empty for other frameworks.
+	MarathonVersion string `json:"marathon_version,omitempty"`
+	// Dependencies lists the deployments the deployment depended on when it
+	// was deployed, in type.name form, so that it can be destroyed before
+	// them once it is no longer configured.
+	Dependencies []string  `json:"dependencies,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Ref returns the DeploymentRef for e.
+func (e *Entry) Ref() model.DeploymentRef {
+	return model.DeploymentRef{
+		Type: e.Type,
+		Name: e.Name,
+	}
+}
+
+// FrameworkRef returns the framework of e.
+func (e *Entry) FrameworkRef() model.FrameworkRef {
+	parts := strings.SplitN(e.Framework, ".", 2)
+	if len(parts) != 2 {
+		return model.FrameworkRef{Name: e.Framework}
+	}
+	return model.FrameworkRef{
+		Type: parts[0],
+		Name: parts[1],
+	}
+}
+
+// DependencyRefs returns the dependencies of e.
+// Returns a non-nil error if one of them is not in type.name form.
+func (e *Entry) DependencyRefs() ([]model.DeploymentRef, error) {
+	refs := make([]model.DeploymentRef, len(e.Dependencies))
+	for i, dependency := range e.Dependencies {
+		ref, err := model.ParseDeploymentRef(dependency)
+		if err != nil {
+			return nil, fmt.Errorf("dependency of %s.%s: %w", e.Type, e.Name, err)
+		}
+		refs[i] = ref
+	}
+	return refs, nil
+}
+
+// Hash returns the hash of a definition, as recorded by DefinitionHash.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// File is an open state file, which is rewritten every time an entry is
+// changed so that an interrupted run loses nothing.
+// Exposed methods are safe to use from multiple concurrent goroutines.
+type File struct {
+	filename string
+	mutex    sync.Mutex
+	state    State
+	entries  map[model.DeploymentRef]*Entry
+}
+
+var _ definition.Source = &File{}
+
+// Open reads the state file with the given name, or starts an empty state if
+// it does not exist yet, in which case it is created by the first change.
+// Returns a non-nil error if the file cannot be read or has a version other
+// than Version.
+func Open(filename string) (*File, error) {
+	f := &File{
+		filename: filename,
+		state:    State{Version: Version},
+		entries:  make(map[model.DeploymentRef]*Entry),
+	}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state: %w", err)
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("parsing state %s: %w", filename, err)
+	} else if f.state.Version != Version {
+		return nil, fmt.Errorf("state %s has version %d, but only version %d is supported", filename,
+			f.state.Version, Version)
+	}
+	for i := range f.state.Deployments {
+		entry := &f.state.Deployments[i]
+		f.entries[entry.Ref()] = entry
+	}
+	return f, nil
+}
+
+// Serial returns the serial number of the state as last written.
+func (f *File) Serial() int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.state.Serial
+}
+
+// Entry returns the entry for ref, if there is one.
+func (f *File) Entry(ref model.DeploymentRef) (Entry, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, ok := f.entries[ref]
+	if !ok {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// Entries returns all entries, sorted by type and then name.
+func (f *File) Entries() []Entry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entries := make([]Entry, 0, len(f.entries))
+	for _, entry := range f.entries {
+		entries = append(entries, *entry)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// Put adds or replaces the entry for a deployment and writes the file.
+// The creation time of a replaced entry is kept.
+func (f *File) Put(entry Entry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if old, ok := f.entries[entry.Ref()]; ok && !old.CreatedAt.IsZero() {
+		entry.CreatedAt = old.CreatedAt
+	}
+	f.entries[entry.Ref()] = &entry
+	return f.write()
+}
+
+// Delete removes the entry for ref, if there is one, and writes the file.
+func (f *File) Delete(ref model.DeploymentRef) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.entries[ref]; !ok {
+		return nil
+	}
+	delete(f.entries, ref)
+	return f.write()
+}
+
+// Load returns a minimal definition of a recorded deployment, with only its
+// app ID or job name, which is enough to identify or destroy it once it is
+// no longer configured.
+func (f *File) Load(ref model.DeploymentRef) ([]byte, error) {
+	entry, ok := f.Entry(ref)
+	if !ok {
+		return nil, fmt.Errorf("deployment %s.%s not in state", ref.Type, ref.Name)
+	}
+	field := "id"
+	if entry.Type == "chronos_job" {
+		field = "name"
+	}
+	return json.Marshal(map[string]string{field: entry.ID})
+}
+
+// write writes the entries to the file with the next serial number, by
+// writing a temporary file and renaming it over the old one.
+// The mutex must be held.
+func (f *File) write() error {
+	deployments := make([]Entry, 0, len(f.entries))
+	for _, entry := range f.entries {
+		deployments = append(deployments, *entry)
+	}
+	sortEntries(deployments)
+	next := State{
+		Version:     Version,
+		Serial:      f.state.Serial + 1,
+		Deployments: deployments,
+	}
+	data, err := json.MarshalIndent(&next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(f.filename), filepath.Base(f.filename)+".tmp")
+	if err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing state: %w", err)
+	}
+	// the data must be on disk before the rename makes it the state
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := os.Rename(temp.Name(), f.filename); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing state: %w", err)
+	}
+	f.state = next
+	f.entries = make(map[model.DeploymentRef]*Entry, len(next.Deployments))
+	for i := range f.state.Deployments {
+		entry := &f.state.Deployments[i]
+		f.entries[entry.Ref()] = entry
+	}
+	return nil
+}
+
+// sortEntries sorts entries by type and then name.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
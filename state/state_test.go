@@ -0,0 +1,170 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+// testFile returns the name of a state file which does not exist yet, in a
+// directory which is removed when the test finishes.
+func testFile(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "mesosdef.state.json")
+}
+
+func TestFile(t *testing.T) {
+	filename := testFile(t)
+	f, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Serial() != 0 || len(f.Entries()) != 0 {
+		t.Fatalf("new state has serial %d and %d entries", f.Serial(), len(f.Entries()))
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("opening created the state file: %v", err)
+	}
+	created := time.Date(2020, 5, 4, 17, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{
+			Type:            "marathon_app",
+			Name:            "web",
+			Framework:       "marathon.default",
+			ID:              "/web",
+			DefinitionHash:  Hash([]byte(`{"id":"/web"}`)),
+			MarathonVersion: "2020-05-04T17:00:00.000Z",
+			Dependencies:    []string{"marathon_app.db"},
+			CreatedAt:       created,
+			UpdatedAt:       created,
+		},
+		{
+			Type:           "chronos_job",
+			Name:           "backup",
+			Framework:      "chronos.default",
+			ID:             "backup job",
+			DefinitionHash: Hash([]byte(`{"name":"backup job"}`)),
+			CreatedAt:      created,
+			UpdatedAt:      created,
+		},
+	}
+	for _, entry := range entries {
+		if err := f.Put(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// replacing an entry keeps its creation time
+	updated := entries[0]
+	updated.CreatedAt = created.Add(time.Hour)
+	updated.UpdatedAt = created.Add(time.Hour)
+	if err := f.Put(updated); err != nil {
+		t.Fatal(err)
+	}
+	if f.Serial() != 3 {
+		t.Errorf("got serial %d after 3 writes", f.Serial())
+	}
+	reopened, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Serial() != 3 {
+		t.Errorf("got serial %d after reopening, want 3", reopened.Serial())
+	}
+	read := reopened.Entries()
+	if len(read) != 2 || read[0].Name != "backup" || read[1].Name != "web" {
+		t.Fatalf("got entries %+v, want backup and web", read)
+	}
+	if !read[1].CreatedAt.Equal(created) || !read[1].UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Errorf("got created %s and updated %s", read[1].CreatedAt, read[1].UpdatedAt)
+	}
+	dependencies, err := read[1].DependencyRefs()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(dependencies) != 1 || dependencies[0] != (model.DeploymentRef{Type: "marathon_app", Name: "db"}) {
+		t.Errorf("got dependencies %v, want marathon_app.db", dependencies)
+	}
+	if framework := read[1].FrameworkRef(); framework != (model.FrameworkRef{Type: "marathon", Name: "default"}) {
+		t.Errorf("got framework %v, want marathon.default", framework)
+	}
+	for _, test := range []struct {
+		ref  model.DeploymentRef
+		want string
+	}{
+		{ref: model.DeploymentRef{Type: "marathon_app", Name: "web"}, want: `{"id":"/web"}`},
+		{ref: model.DeploymentRef{Type: "chronos_job", Name: "backup"}, want: `{"name":"backup job"}`},
+	} {
+		content, err := reopened.Load(test.ref)
+		if err != nil {
+			t.Errorf("%s: %v", test.ref.Name, err)
+		} else if string(content) != test.want {
+			t.Errorf("%s: got definition %s, want %s", test.ref.Name, content, test.want)
+		}
+	}
+	web := model.DeploymentRef{Type: "marathon_app", Name: "web"}
+	if err := reopened.Delete(web); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Entry(web); ok {
+		t.Error("deleted entry still present")
+	}
+	if _, err := reopened.Load(web); err == nil {
+		t.Error("loading deleted entry: no error")
+	}
+	// deleting an entry which is not recorded does not write
+	if err := reopened.Delete(web); err != nil {
+		t.Fatal(err)
+	} else if reopened.Serial() != 4 {
+		t.Errorf("got serial %d after 4 writes", reopened.Serial())
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 {
+		t.Errorf("got %d files, want only the state file", len(files))
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "not JSON", content: `state`, err: "parsing state"},
+		{name: "other version", content: `{"version":2,"serial":1}`,
+			err: "has version 2, but only version 1 is supported"},
+		{name: "current version", content: `{"version":1,"serial":7,"deployments":[]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := testFile(t)
+			if err := ioutil.WriteFile(filename, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := Open(filename)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if f.Serial() != 7 {
+				t.Errorf("got serial %d, want 7", f.Serial())
+			}
+		})
+	}
+}
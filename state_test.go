@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/deploy"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// unhealthyDeployer is a deploy.Deployer, deploy.Identifier, and
+// definition.Source for which deployments named unhealthy never become
+// healthy.
+type unhealthyDeployer struct{}
+
+func (unhealthyDeployer) Deploy(ref model.DeploymentRef) error {
+	return nil
+}
+
+func (unhealthyDeployer) WaitUntilHealthy(ref model.DeploymentRef) error {
+	if ref.Name == "unhealthy" {
+		return fmt.Errorf("unhealthy")
+	}
+	return nil
+}
+
+func (unhealthyDeployer) Load(ref model.DeploymentRef) ([]byte, error) {
+	return []byte(`{"id":"/` + ref.Name + `"}`), nil
+}
+
+func (unhealthyDeployer) Identify(ref model.DeploymentRef) (string, string, error) {
+	return "/" + ref.Name, "", nil
+}
+
+// TestStateRecorder checks that deployments are recorded once deployed even
+// if they never become healthy, that unchanged deployments are recorded, and
+// that deployments which are only waited on are not.
+func TestStateRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mesosdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	flagState = filepath.Join(dir, "mesosdef.state.json")
+	t.Cleanup(func() {
+		flagState = ""
+	})
+	root := &model.Root{}
+	var refs []model.DeploymentRef
+	for _, name := range []string{"unhealthy", "dependent", "unchanged", "waited"} {
+		root.Deployments = append(root.Deployments, model.Deployment{
+			Type:      "marathon_app",
+			Name:      name,
+			Framework: "default",
+		})
+		refs = append(refs, root.Deployments[len(root.Deployments)-1].Ref())
+	}
+	var graph model.Graph
+	err = graph.BuildFromEdges(refs, []model.Edge{
+		{From: refs[0], To: refs[3]},
+		{From: refs[1], To: refs[0], WaitForHealthy: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := newStateRecorder(root, &graph, unhealthyDeployer{}, unhealthyDeployer{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphDeployer, err := deploy.NewGraphDeployer(&graph, unhealthyDeployer{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphDeployer.SetExisting(refs[2], refs[3])
+	recorder.setUnchanged(refs[2])
+	events := make(chan deploy.Event, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			recorder.record(event)
+		}
+	}()
+	graphDeployer.Deploy(events)
+	<-done
+	if recorder.err != nil {
+		t.Fatal(recorder.err)
+	}
+	var recorded []string
+	for _, entry := range recorder.file.Entries() {
+		recorded = append(recorded, fmt.Sprintf("%s %s %v", entry.Name, entry.ID, entry.Dependencies))
+	}
+	want := "unchanged /unchanged [], unhealthy /unhealthy [marathon_app.waited]"
+	if got := strings.Join(recorded, ", "); got != want {
+		t.Errorf("got entries %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+var (
+	flagNoDeps         bool
+	flagWithDependents bool
+	flagTargets        stringSliceValue
+	flagTargetLabels   stringSliceValue
+	flagSelect         stringSliceValue
+	flagExclude        stringSliceValue
+)
+
+// addSelectionFlags adds the flags used by selectDeployments to flags.
+func addSelectionFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&flagNoDeps, "no-deps", false, "do not deploy the dependencies of targets, only wait on them")
+	flags.BoolVar(&flagWithDependents, "with-dependents", false, "restart everything which depends on targets")
+	addTargetFlags(flags, "deploy", "dependencies")
+}
+
+// addTargetFlags adds the target, select, and exclude flags to flags, with
+// usage naming the given action and the related deployments it includes.
+func addTargetFlags(flags *flag.FlagSet, action, related string) {
+	flags.Var(&flagTargets, "target", fmt.Sprintf("%s only deployment type.name and its %s, can be repeated",
+		action, related))
+	flags.Var(&flagTargetLabels, "target-label", fmt.Sprintf("%s only deployments with a label and their %s, "+
+		"can be repeated", action, related))
+	flags.Var(&flagSelect, "select", fmt.Sprintf("%s only deployments matching a filter such as "+
+		"labels=monitoring and their %s, can be repeated to match all", action, related))
+	flags.Var(&flagExclude, "exclude", fmt.Sprintf("do not %s deployments matching a filter such as "+
+		"name=*_cleanup, can be repeated to match any", action))
+}
+
+// selection is the part of a graph chosen to be deployed by the target flags.
+type selection struct {
+	// graph holds the deployments to deploy and the existing deployments
+	// they wait on, along with the edges between them.
+	graph *model.Graph
+	// existing holds the deployments of graph which are only waited on.
+	existing []model.DeploymentRef
+	// restarted holds the deployments of graph which are restarted because
+	// they depend on a target.
+	restarted []model.DeploymentRef
+	// warnings describe excluded deployments which are dependencies of
+	// selected ones, and so are only waited on.
+	warnings []string
+}
+
+// selectDeployments applies the target, select, and exclude flags to the
+// graph of cfg.
+// If no targets are given, every deployment is selected.
+// Otherwise, the targeted deployments are selected along with all of their
+// dependencies or, with -no-deps, along with their direct dependencies as
+// existing deployments.
+// Deployments which depend on a target by an edge with restart_on_change, or
+// by any edge with -with-dependents, are selected to be restarted, along with
+// their direct dependencies as existing deployments.
+// Finally, excluded deployments are removed from the selection, or made
+// existing deployments with a warning if a selected deployment depends on
+// them.
+func selectDeployments(cfg *config) (*selection, error) {
+	graph := &cfg.graph
+	excluded, err := resolveExcluded(&cfg.root)
+	if err != nil {
+		return nil, err
+	}
+	targeting := len(flagTargets) != 0 || len(flagTargetLabels) != 0 || len(flagSelect) != 0
+	if flagWithDependents && !targeting {
+		return nil, fmt.Errorf("-with-dependents requires -target, -target-label, or -select")
+	}
+	if !targeting && len(excluded) == 0 {
+		return &selection{graph: graph}, nil
+	}
+	var targets []model.DeploymentRef
+	if targeting {
+		if targets, err = resolveTargets(&cfg.root); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range cfg.root.Deployments {
+			targets = append(targets, cfg.root.Deployments[i].Ref())
+		}
+	}
+	restarted, err := findDependents(graph, targets)
+	if err != nil {
+		return nil, err
+	}
+	included := targets
+	if !flagNoDeps {
+		if included, err = graph.WithDependencies(targets); err != nil {
+			return nil, err
+		}
+	}
+	included = append(included, restarted...)
+	var remaining []model.DeploymentRef
+	for _, ref := range included {
+		if !excluded[ref] {
+			remaining = append(remaining, ref)
+		}
+	}
+	included = remaining
+	// wait on the direct dependencies of everything else
+	seen := make(map[model.DeploymentRef]bool, len(included))
+	for _, ref := range included {
+		seen[ref] = true
+	}
+	result := &selection{}
+	dependentsOfExcluded := make(map[model.DeploymentRef][]string)
+	for _, ref := range included {
+		dependencies, err := graph.Dependencies(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependency := range dependencies {
+			depRef := dependency.DeploymentRef()
+			if excluded[depRef] {
+				dependentsOfExcluded[depRef] = append(dependentsOfExcluded[depRef],
+					fmt.Sprintf("%s.%s", ref.Type, ref.Name))
+			}
+			if !seen[depRef] {
+				seen[depRef] = true
+				result.existing = append(result.existing, depRef)
+			}
+		}
+	}
+	for _, ref := range result.existing {
+		if dependents := dependentsOfExcluded[ref]; len(dependents) != 0 {
+			result.warnings = append(result.warnings, fmt.Sprintf("%s.%s is excluded, but selected deployment(s) "+
+				"%s depend on it, so it will not be deployed and is assumed to exist already", ref.Type, ref.Name,
+				strings.Join(dependents, ", ")))
+		}
+	}
+	for _, ref := range restarted {
+		if !excluded[ref] {
+			result.restarted = append(result.restarted, ref)
+		}
+	}
+	if result.graph, err = graph.Subgraph(append(included, result.existing...)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// selectDestroyed applies the target, select, and exclude flags to the graph
+// of cfg for destroy.
+// If no targets are given, every deployment is selected.
+// Otherwise, the targeted deployments are selected along with everything
+// which depends on them, directly or indirectly.
+// Finally, excluded deployments are removed from the selection, with a
+// warning if they depend on a selected deployment, since they are left in
+// place without it.
+func selectDestroyed(cfg *config) (*selection, error) {
+	graph := &cfg.graph
+	excluded, err := resolveExcluded(&cfg.root)
+	if err != nil {
+		return nil, err
+	}
+	targeting := len(flagTargets) != 0 || len(flagTargetLabels) != 0 || len(flagSelect) != 0
+	if !targeting && len(excluded) == 0 {
+		return &selection{graph: graph}, nil
+	}
+	var included []model.DeploymentRef
+	if targeting {
+		targets, err := resolveTargets(&cfg.root)
+		if err != nil {
+			return nil, err
+		}
+		if included, err = graph.WithDependents(targets); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range cfg.root.Deployments {
+			included = append(included, cfg.root.Deployments[i].Ref())
+		}
+	}
+	destroyed := make(map[model.DeploymentRef]bool, len(included))
+	for _, ref := range included {
+		destroyed[ref] = !excluded[ref]
+	}
+	result := &selection{}
+	var remaining []model.DeploymentRef
+	for _, ref := range included {
+		if !excluded[ref] {
+			remaining = append(remaining, ref)
+			continue
+		}
+		dependencies, err := graph.Dependencies(ref)
+		if err != nil {
+			return nil, err
+		}
+		var missing []string
+		for _, dependency := range dependencies {
+			depRef := dependency.DeploymentRef()
+			if destroyed[depRef] {
+				missing = append(missing, fmt.Sprintf("%s.%s", depRef.Type, depRef.Name))
+			}
+		}
+		if len(missing) != 0 {
+			result.warnings = append(result.warnings, fmt.Sprintf("%s.%s is excluded, but it depends on selected "+
+				"deployment(s) %s, so it will be left without them", ref.Type, ref.Name,
+				strings.Join(missing, ", ")))
+		}
+	}
+	if result.graph, err = graph.Subgraph(remaining); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resolveExcluded returns the deployments of root matching any filter given
+// by -exclude.
+func resolveExcluded(root *model.Root) (map[model.DeploymentRef]bool, error) {
+	filters, err := parseFilters(flagExclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude: %w", err)
+	}
+	excluded := make(map[model.DeploymentRef]bool)
+	for i := range root.Deployments {
+		deployment := &root.Deployments[i]
+		for j := range filters {
+			matches, err := filters[j].Matches(deployment)
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				excluded[deployment.Ref()] = true
+				break
+			}
+		}
+	}
+	return excluded, nil
+}
+
+// parseFilters parses each of the given filters.
+func parseFilters(values []string) ([]model.Filter, error) {
+	filters := make([]model.Filter, len(values))
+	for i, value := range values {
+		filter, err := model.ParseFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+	return filters, nil
+}
+
+// findDependents walks the reverse edges of graph from targets and returns
+// the deployments, other than the targets, which depend on them by an edge
+// with restart_on_change, or by any edge with -with-dependents.
+func findDependents(graph *model.Graph, targets []model.DeploymentRef) ([]model.DeploymentRef, error) {
+	seen := make(map[model.DeploymentRef]bool, len(targets))
+	for _, ref := range targets {
+		seen[ref] = true
+	}
+	var dependents []model.DeploymentRef
+	queue := append([]model.DeploymentRef(nil), targets...)
+	for len(queue) != 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		edges, err := graph.Dependents(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, edge := range edges {
+			if seen[edge.From] || !flagWithDependents && !edge.RestartOnChange {
+				continue
+			}
+			seen[edge.From] = true
+			dependents = append(dependents, edge.From)
+			queue = append(queue, edge.From)
+		}
+	}
+	return dependents, nil
+}
+
+// resolveTargets returns the deployments of root named by -target, either by
+// their full name or by their name without an instance key, having a label
+// given by -target-label, or matching every filter given by -select, in
+// declaration order.
+func resolveTargets(root *model.Root) ([]model.DeploymentRef, error) {
+	selected := make([]bool, len(root.Deployments))
+	for _, target := range flagTargets {
+		ref, err := model.ParseDeploymentRef(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target: %w", err)
+		}
+		found := false
+		for i := range root.Deployments {
+			deployment := &root.Deployments[i]
+			if deployment.Type == ref.Type &&
+				(deployment.Name == ref.Name || model.BaseName(deployment.Name) == ref.Name) {
+				selected[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("target deployment %s.%s not found", ref.Type, ref.Name)
+		}
+	}
+	for _, label := range flagTargetLabels {
+		found := false
+		for i := range root.Deployments {
+			for _, deploymentLabel := range root.Deployments[i].Labels {
+				if deploymentLabel == label {
+					selected[i] = true
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no deployment has target label \"%s\"", label)
+		}
+	}
+	if len(flagSelect) != 0 {
+		filters, err := parseFilters(flagSelect)
+		if err != nil {
+			return nil, fmt.Errorf("invalid select: %w", err)
+		}
+		found := false
+		for i := range root.Deployments {
+			allMatch := true
+			for j := range filters {
+				matches, err := filters[j].Matches(&root.Deployments[i])
+				if err != nil {
+					return nil, err
+				}
+				if !matches {
+					allMatch = false
+					break
+				}
+			}
+			if allMatch {
+				selected[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no deployment matches all of the select filters %s", strings.Join(flagSelect, ", "))
+		}
+	}
+	var targets []model.DeploymentRef
+	for i := range root.Deployments {
+		if selected[i] {
+			targets = append(targets, root.Deployments[i].Ref())
+		}
+	}
+	return targets, nil
+}
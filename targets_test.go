@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kbolino/mesosdef/model"
+)
+
+func TestResolveTargetsBaseName(t *testing.T) {
+	root := &model.Root{
+		Deployments: []model.Deployment{
+			{Type: "marathon_app", Name: `worker["eu"]`},
+			{Type: "marathon_app", Name: "web"},
+			{Type: "marathon_app", Name: `worker["us"]`},
+			{Type: "chronos_job", Name: "worker[0]"},
+			{Type: "marathon_app", Name: "worker_cleanup"},
+		},
+	}
+	tests := []struct {
+		targets []string
+		refs    string
+		err     string
+	}{
+		{targets: []string{"marathon_app.worker"}, refs: `marathon_app.worker["eu"] marathon_app.worker["us"]`},
+		{targets: []string{`marathon_app.worker["us"]`}, refs: `marathon_app.worker["us"]`},
+		{targets: []string{"chronos_job.worker"}, refs: "chronos_job.worker[0]"},
+		{targets: []string{"chronos_job.worker[0]", "marathon_app.web"}, refs: "marathon_app.web chronos_job.worker[0]"},
+		{targets: []string{`marathon_app.worker["ap"]`}, err: `target deployment marathon_app.worker["ap"] not found`},
+		{targets: []string{"marathon_app.work"}, err: "target deployment marathon_app.work not found"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.targets, " "), func(t *testing.T) {
+			flagTargets = test.targets
+			t.Cleanup(func() {
+				flagTargets = nil
+			})
+			refs, err := resolveTargets(root)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			formatted := make([]string, len(refs))
+			for i, ref := range refs {
+				formatted[i] = ref.Type + "." + ref.Name
+			}
+			if actual := strings.Join(formatted, " "); actual != test.refs {
+				t.Errorf("got %s, want %s", actual, test.refs)
+			}
+		})
+	}
+}
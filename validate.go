@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kbolino/mesosdef/definition"
+	"github.com/kbolino/mesosdef/model"
+)
+
+// validateMain is the entry point for the validate subcommand.
+func validateMain(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var checkDefs bool
+	flags.BoolVar(&checkDefs, "definitions", false, "also load and check the definitions of all deployments")
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate [options]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Checks that the configuration is valid and has no dependency cycles.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkCycles(&cfg.graph); err != nil {
+		return err
+	}
+	// load all definitions if requested, reporting all failures at once
+	if checkDefs {
+		loader := definition.NewLoader(&cfg.root)
+		var failures []string
+		for i := range cfg.root.Deployments {
+			if _, err := loader.Load(cfg.root.Deployments[i].Ref()); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		if len(failures) != 0 {
+			return fmt.Errorf("invalid definition(s):\n\t%s", strings.Join(failures, "\n\t"))
+		}
+	}
+	fmt.Printf("%d deployments and %d dependencies are valid\n", len(cfg.root.Deployments),
+		len(cfg.graph.Edges()))
+	return nil
+}
+
+// definitionMain is the entry point for the definition subcommand.
+func definitionMain(args []string) error {
+	flags := flag.NewFlagSet("definition", flag.ContinueOnError)
+	addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s definition [options] type.name\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Prints the final definition of a deployment, after rendering its template\n"+
+			"and merging it with defaults and overrides.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("definition requires exactly one deployment, got %d", flags.NArg())
+	}
+	ref, err := model.ParseDeploymentRef(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	content, err := definition.NewLoader(&cfg.root).Load(ref)
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, content, "", "  "); err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", indented.Bytes())
+	return nil
+}